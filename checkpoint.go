@@ -0,0 +1,299 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"syscall"
+	"time"
+
+	. "github.com/munnik/cubes/shape"
+	"github.com/munnik/cubes/store"
+)
+
+// checkpoint records which roots are completely grown, the shapes of those roots are written to the file and counted in
+// the counter
+type checkpoint struct {
+	MaxSize     ShapeSize `json:"maxSize"`
+	Equivalence string    `json:"equivalence"`
+	Shard       int       `json:"shard"`
+	Shards      int       `json:"shards"`
+	Box         string    `json:"box,omitempty"`
+	Dimensions  int       `json:"dimensions,omitempty"` // 0 is the same as 3 dimensions
+	Adjacency   string    `json:"adjacency,omitempty"`  // empty is the same as face adjacency
+	ParentSize  ShapeSize `json:"parentSize"`           // the size of the shapes the run started from
+	RootSize    ShapeSize `json:"rootSize"`
+	Roots       int       `json:"roots"`
+	Done        []int     `json:"done"` // indexes of the roots that are done, the roots are ordered by their score
+	Counter     *Counter  `json:"counter"`
+}
+
+func readCheckpoint(path string) (*checkpoint, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &checkpoint{}
+	if err := json.Unmarshal(data, result); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// returns an error that names the first setting of the run that differs from the setting of the previous run
+func (c *checkpoint) compatible(previous *checkpoint) error {
+	settings := []struct {
+		name            string
+		value, previous interface{}
+	}{
+		{"maximum size", c.MaxSize, previous.MaxSize},
+		{"equivalence", c.Equivalence, previous.Equivalence},
+		{"shard", c.Shard, previous.Shard},
+		{"number of shards", c.Shards, previous.Shards},
+		{"box", c.Box, previous.Box},
+		{"number of dimensions", c.Dimensions, previous.Dimensions},
+		{"adjacency", c.Adjacency, previous.Adjacency},
+		{"size of the parents", c.ParentSize, previous.ParentSize},
+		{"size of the roots", c.RootSize, previous.RootSize},
+		{"number of roots", c.Roots, previous.Roots},
+	}
+	for _, setting := range settings {
+		if setting.value != setting.previous {
+			return fmt.Errorf("checkpoint was created with %q as the %s but the run has %q", fmt.Sprint(setting.previous), setting.name, fmt.Sprint(setting.value))
+		}
+	}
+
+	return nil
+}
+
+// writes the checkpoint to a temporary file first so an existing checkpoint is never partly overwritten
+func (c *checkpoint) write(path string) error {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path+".tmp", data, 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(path+".tmp", path)
+}
+
+// the shapes grown from one root, when done is true all shapes of the root are sent and counter contains all these shapes
+type rootResult struct {
+	shape   *Shape
+	root    int
+	done    bool
+	counter *Counter
+}
+
+// growWithCheckpoints grows the parents until maxSize is reached using canonical augmentation, like growCanonical and
+// countCanonical. The parents are grown into roots and every root is grown by one worker. The finished roots and the
+// counter are written to the checkpoint file every interval and when the process receives SIGINT or SIGTERM. When resume
+// is true growing continues from the checkpoint and the shapes of unfinished roots are removed from the file, an error is
+// returned if the checkpoint was created with other settings.
+func growWithCheckpoints(pool *Pool, equivalence Equivalence, found Shapes, parents []*Shape, maxSize ShapeSize, fileName string, format string, headers []store.Header, checkpointFileName string, interval time.Duration, resume bool, shard int, shards int) (*Counter, error) {
+	roots := splitRoots(pool, equivalence, found, parents, maxSize)
+	sort.Slice(roots, func(i, j int) bool {
+		return roots[i].Score() < roots[j].Score()
+	})
+
+	current := &checkpoint{
+		MaxSize:     maxSize,
+		Equivalence: equivalence.String(),
+		Shard:       shard,
+		Shards:      shards,
+		Roots:       len(roots),
+		Done:        make([]int, 0),
+		Counter:     NewCounter(equivalence),
+	}
+	if len(parents) > 0 && parents[0].Dimensions() != 3 {
+		current.Dimensions = parents[0].Dimensions()
+	}
+	if len(parents) > 0 && parents[0].Adjacency() != Face {
+		current.Adjacency = parents[0].Adjacency().String()
+	}
+	if pool.Box() != nil {
+		current.Box = pool.Box().String()
+	}
+	if len(roots) > 0 {
+		current.ParentSize = parents[0].Size()
+		current.RootSize = roots[0].Size()
+	}
+
+	var w store.Store
+	var err error
+	if resume {
+		previous, err := readCheckpoint(checkpointFileName)
+		if err != nil {
+			return nil, err
+		}
+		if err := current.compatible(previous); err != nil {
+			return nil, fmt.Errorf("%s: %w", checkpointFileName, err)
+		}
+		current = previous
+		if fileName != "" {
+			if err := removeUnfinishedRoots(fileName, format, equivalence, roots, current.Done); err != nil {
+				return nil, err
+			}
+			if w, err = store.Append(fileName, format); err != nil {
+				return nil, err
+			}
+		}
+		fmt.Printf("Resuming from checkpoint, %d of %d roots are done\n", len(current.Done), current.Roots)
+	} else {
+		if fileName != "" {
+			if w, err = store.Create(fileName, format, equivalence, headers...); err != nil {
+				return nil, err
+			}
+		}
+		IterateAll(found, func(shape *Shape) bool {
+			current.Counter.Add(shape)
+			if w != nil {
+				if err := w.Write(shape); err != nil {
+					panic(err)
+				}
+			}
+			return true
+		})
+	}
+
+	save := func() {
+		if w != nil {
+			if err := w.Flush(); err != nil {
+				panic(err)
+			}
+		}
+		if err := current.write(checkpointFileName); err != nil {
+			panic(err)
+		}
+	}
+	save()
+
+	done := make(map[int]struct{}, len(current.Done))
+	for _, root := range current.Done {
+		done[root] = struct{}{}
+	}
+	todo := make([]*Shape, 0, len(roots)-len(done))
+	indexes := make(map[Score]int, len(roots))
+	for i, root := range roots {
+		indexes[root.Score()] = i
+		if _, ok := done[i]; !ok {
+			todo = append(todo, root)
+		}
+	}
+
+	results := make(chan rootResult, 1024)
+	go func() {
+		pool.Run(todo, func(worker int, root *Shape) []*Shape {
+			index := indexes[root.Score()]
+			counter := NewCounter(equivalence)
+			root.KeepGrowingCanonicalInBox(equivalence, maxSize, pool.Box(), func(s *Shape) {
+				counter.Add(s)
+				if observer := pool.Observer(); observer != nil {
+					observer.Found(s)
+				}
+				if w != nil {
+					results <- rootResult{shape: s, root: index}
+				}
+			})
+			results <- rootResult{root: index, done: true, counter: counter}
+			return nil
+		})
+		close(results)
+	}()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(signals)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case result, ok := <-results:
+			if !ok {
+				save()
+				if w != nil {
+					if err := w.Close(); err != nil {
+						panic(err)
+					}
+				}
+				return current.Counter, nil
+			}
+			if !result.done {
+				if err := w.Write(result.shape); err != nil {
+					panic(err)
+				}
+				continue
+			}
+			current.Counter.Merge(result.counter)
+			current.Done = append(current.Done, result.root)
+		case <-ticker.C:
+			save()
+		case s := <-signals:
+			save()
+			fmt.Printf("Received %v, %d of %d roots are done, continue with -resume\n", s, len(current.Done), current.Roots)
+			os.Exit(1)
+		}
+	}
+}
+
+// removeUnfinishedRoots removes the shapes from the file that are grown from a root that is not done, the shapes that are
+// not grown from a root are kept. The root of a shape is found by removing canonical cubes until the shape has the size of
+// the roots.
+func removeUnfinishedRoots(fileName string, format string, equivalence Equivalence, roots []*Shape, done []int) error {
+	finished := make(map[Score]struct{}, len(done))
+	for _, root := range done {
+		finished[roots[root].Score()] = struct{}{}
+	}
+	rootSize := ShapeSize(0)
+	if len(roots) > 0 {
+		rootSize = roots[0].Size()
+	}
+
+	headers, err := store.ReadHeaders(fileName, format)
+	if err != nil {
+		return err
+	}
+	otherHeaders := make([]store.Header, 0, len(headers))
+	for _, header := range headers {
+		// the writer writes the format, version and equivalence headers itself and the counts change
+		switch header.Key {
+		case store.FORMAT_HEADER, store.VERSION_HEADER, store.EQUIVALENCE_HEADER, store.COUNT_HEADER:
+		default:
+			otherHeaders = append(otherHeaders, header)
+		}
+	}
+
+	w, err := store.Create(fileName+".tmp", format, equivalence, otherHeaders...)
+	if err != nil {
+		return err
+	}
+	err = store.ScanShapes(fileName, format, func(s *Shape) error {
+		if s.Size() > rootSize {
+			root := s
+			for root.Size() > rootSize {
+				root = root.CanonicalParent(equivalence)
+			}
+			if _, ok := finished[root.Score()]; !ok {
+				return nil
+			}
+		}
+
+		return w.Write(s)
+	})
+	if err != nil {
+		w.Close()
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(fileName+".tmp", fileName)
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	. "github.com/munnik/cubes/shape"
+	"github.com/munnik/cubes/store"
+)
+
+// the headers of a file that are not copied to a converted file, they are written again for the converted file
+var convertedHeaders = map[string]bool{
+	store.FORMAT_HEADER:      true,
+	store.VERSION_HEADER:     true,
+	store.EQUIVALENCE_HEADER: true,
+	store.CHECKSUM_HEADER:    true,
+}
+
+// convert converts a file in one of the formats of the store to a file in another format
+func convert(args []string) {
+	var memoryBudget int
+	var inputFormat, outputFormat string
+	formats := strings.Join(store.FormatNames(), ", ")
+	flags := flag.NewFlagSet("convert", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s convert [flags] input output\n", os.Args[0])
+		fmt.Fprintln(flags.Output(), "The shapes of the input file are written to the output file in another format.")
+		flags.PrintDefaults()
+	}
+	flags.StringVar(&inputFormat, "input-format", "", fmt.Sprintf("Format of the input file. Options are %s. If not specified the format is detected from the first bytes of the file or chosen by the extension of the file name, %s if the extension is unknown.", formats, store.DEFAULT_FORMAT))
+	flags.StringVar(&outputFormat, "output-format", "", fmt.Sprintf("Format of the output file. Options are %s. If not specified the format is chosen by the extension of the file name, %s if the extension is unknown.", formats, store.DEFAULT_FORMAT))
+	flags.IntVar(&memoryBudget, "memory-budget", DefaultDiskBudget>>20, "Number of MiB of the shapes that are kept in memory to sort them before they are written to temporary files when the output file is binary, the files are written to the directory in TMPDIR.")
+	flags.Parse(args)
+	if flags.NArg() != 2 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	input, output := flags.Arg(0), flags.Arg(1)
+	store.BinaryMemoryBudget = int64(memoryBudget) << 20
+
+	r, err := store.Open(input, inputFormat, true)
+	if err != nil {
+		panic(err)
+	}
+	defer r.Close()
+	equivalence, err := store.HeaderEquivalence(r.Headers())
+	if err != nil {
+		panic(err)
+	}
+
+	w, err := store.Create(output, outputFormat, equivalence, copiedHeaders(r.Headers())...)
+	if err != nil {
+		panic(err)
+	}
+	for {
+		s, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			panic(err)
+		}
+		if err := w.Write(s); err != nil {
+			panic(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		panic(err)
+	}
+}
+
+// returns the headers that are copied to a converted file
+func copiedHeaders(headers []store.Header) []store.Header {
+	result := make([]store.Header, 0, len(headers))
+	for _, header := range headers {
+		if !convertedHeaders[header.Key] {
+			result = append(result, header)
+		}
+	}
+
+	return result
+}
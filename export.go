@@ -0,0 +1,82 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	. "github.com/munnik/cubes/shape"
+	"github.com/munnik/cubes/store"
+)
+
+// export writes a table with the properties of every shape in the files, the shapes are read one at a time so the files
+// can be bigger than the memory
+func export(args []string) {
+	var outputFileName string
+	var filterString string
+	var sortString string
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s export [flags] files...\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	flags.StringVar(&outputFileName, "o", "", "File name the tab separated table is written to, with the geometric properties, the number of cavities, the number of tunnels and the Euler characteristic of every polycube. Tunnels are only written for up to 3 dimensions. If no file name is specified the table is written to standard output.")
+	flags.StringVar(&filterString, "filter", "", "Comma separated filters on the columns of the table, only polycubes that pass all filters are written, for example cavities>0,size<=20. Operators are <, <=, =, !=, >= and >.")
+	flags.StringVar(&sortString, "sort", "", "Comma separated columns to sort the table on, prefix a column with - to sort the biggest values first, for example size,-diameter. All rows are kept in memory when the table is sorted. If not specified the polycubes are written in the order of the files.")
+	flags.Parse(args)
+
+	dimensions := 0
+	for _, path := range flags.Args() {
+		headers, err := store.ReadHeaders(path, "")
+		if err != nil {
+			panic(err)
+		}
+		d, err := store.Dimensions(headers)
+		if err != nil {
+			panic(err)
+		}
+		if dimensions != 0 && d != dimensions {
+			panic(fmt.Sprintf("%s contains shapes with %d dimensions but other files with %d dimensions", path, d, dimensions))
+		}
+		dimensions = d
+	}
+
+	columns := append(store.PropertyColumns(dimensions), store.TopologyColumns(dimensions)...)
+	w, err := store.NewTableWriter(outputFileName, columns)
+	if err != nil {
+		panic(err)
+	}
+	if filterString != "" {
+		filters := make([]store.Filter, 0)
+		for _, s := range strings.Split(filterString, ",") {
+			filter, err := store.FilterFromString(s)
+			if err != nil {
+				panic(err)
+			}
+			filters = append(filters, filter)
+		}
+		if err := w.SetFilters(filters); err != nil {
+			panic(err)
+		}
+	}
+	if sortString != "" {
+		orders := make([]store.Order, 0)
+		for _, s := range strings.Split(sortString, ",") {
+			orders = append(orders, store.OrderFromString(s))
+		}
+		if err := w.SetOrders(orders); err != nil {
+			panic(err)
+		}
+	}
+	for _, path := range flags.Args() {
+		if err := store.ScanShapes(path, "", func(s *Shape) error {
+			return w.Write(s)
+		}); err != nil {
+			panic(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		panic(err)
+	}
+}
@@ -1,82 +1,523 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
 	"sync"
+	"time"
 
 	. "github.com/munnik/cubes/shape"
 	"github.com/munnik/cubes/store"
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "merge" {
+		merge(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "export" {
+		export(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "convert" {
+		convert(os.Args[2:])
+		return
+	}
+
 	var maxSize int
 	var fileName string
 	var imagePath string
 	var method string
+	var countOnly bool
+	var equivalenceName string
+	var symmetry bool
+	var workers int
+	var shard int
+	var shards int
+	var checkpointFileName string
+	var checkpointInterval time.Duration
+	var resume bool
+	var progressInterval time.Duration
+	var httpAddress string
+	var memoryBudget int
+	var levels bool
+	var memo bool
+	var memoSize int
+	var boxString string
+	var dimensions int
+	var adjacencyName string
+	var format string
 	flag.IntVar(&maxSize, "n", 1, "Specify the maximum number of cubes a polycube can consist of. All unique polycubes from 1 to n cubes are calculated.")
 	flag.StringVar(&fileName, "f", "", "File name to read existing polycubes from, new polycubes are written to this file. If no file name is specified no file is used to read from or write to.")
+	flag.StringVar(&format, "format", "", fmt.Sprintf("Format of the file specified with -f. Options are %s. If not specified the format is chosen by the extension of the file name, %s if the extension is unknown.", strings.Join(store.FormatNames(), ", "), store.DEFAULT_FORMAT))
 	flag.StringVar(&imagePath, "i", "", "Path were images should be written, existing images will be overwritten. If not specified no images will be generated")
-	flag.StringVar(&method, "m", "DefaultMap", "Method to use to create a set of all the shapes created. Options are DefaultMap and LongestStraightMap.")
+	flag.StringVar(&method, "m", "DefaultMap", "Method to use to create a set of all the shapes created. Options are DefaultMap, LongestStraightMap, Concurrent, Disk and Canonical. Concurrent lets all workers add to the same set of shapes. Disk writes the shapes to temporary files when the memory budget is used. Canonical doesn't keep the shapes in memory but writes them directly to the file and images.")
+	flag.BoolVar(&countOnly, "count-only", false, "Only count the free, one-sided and fixed polycubes for every size from 1 to n without keeping the shapes in memory, the polycubes are enumerated using the equivalence specified with -e. No file is written and no images are generated.")
+	flag.StringVar(&equivalenceName, "e", OneSided.String(), "Equivalence that defines which polycubes are the same. Options are one-sided (rotations), free (rotations and reflections) and fixed (translations only).")
+	flag.BoolVar(&symmetry, "symmetry", false, "Print the number of polycubes per point group for every size from 1 to n.")
+	flag.IntVar(&workers, "workers", runtime.GOMAXPROCS(0), "Number of workers that grow polycubes concurrently.")
+	flag.IntVar(&shard, "shard", 0, "Index of the shard to enumerate, from 0 up to the number of shards.")
+	flag.IntVar(&shards, "shards", 1, "Number of shards the enumeration is split into. Every shard writes its polycubes to the file name with the suffix .<shard>-of-<shards>, the suffix comes before an extension the format is chosen by, use the merge subcommand to combine the files of all shards.")
+	flag.StringVar(&checkpointFileName, "checkpoint", "", "File name to write checkpoints to, only for the Canonical method and -count-only. If no file name is specified no checkpoints are written. Images are not generated when checkpoints are written.")
+	flag.DurationVar(&checkpointInterval, "checkpoint-interval", 5*time.Minute, "Time between two checkpoints.")
+	flag.BoolVar(&resume, "resume", false, "Continue from the checkpoint instead of starting over, the same flags as the interrupted run should be used.")
+	flag.DurationVar(&progressInterval, "progress", 0, "Time between two progress reports that are printed to stderr. If not specified no progress is reported.")
+	flag.StringVar(&httpAddress, "http", "", "Address to serve the progress and run metrics as JSON on /debug/vars, for example localhost:6060. If not specified no HTTP server is started.")
+	flag.IntVar(&memoryBudget, "memory-budget", DefaultDiskBudget>>20, "Number of MiB the Disk method keeps in memory before it writes shapes to temporary files, the files are written to the directory in TMPDIR.")
+	flag.BoolVar(&levels, "levels", false, "Grow the polycubes one size at a time, every polycube of a size is grown once. All polycubes of a size are written to the file before the next size is grown and only one size is kept in memory. Not supported by the Canonical method and -count-only.")
+	flag.BoolVar(&memo, "memo", true, "Remember the polycubes that are already grown so the same polycubes are not grown again, only for the DefaultMap, LongestStraightMap, Concurrent and Disk methods.")
+	flag.IntVar(&memoSize, "memo-size", DefaultVisitedCapacity, "Maximum number of polycubes that are remembered by -memo, the least recently used polycubes are forgotten first. 0 remembers all polycubes, which is not allowed for the Disk method because all polycubes would be kept in memory.")
+	flag.StringVar(&boxString, "box", "", "Only polycubes that fit in a box of WxHxD cubes in some orientation are grown, for example 4x4x2. The number of polycubes that fit is printed for every size from 1 to n. If not specified all polycubes are grown.")
+	flag.IntVar(&dimensions, "d", 3, fmt.Sprintf("Number of dimensions the polycubes grow in, from 1 up to and including %d. Use 2 for polyominoes and 4 for polytesseracts. Images can only be generated for up to 3 dimensions and point groups only for 3 dimensions.", MaxDimensions))
+	flag.StringVar(&adjacencyName, "adjacency", Face.String(), "Adjacency that defines which cubes are connected. Options are face (6 neighbors in 3 dimensions), edge (18 neighbors, cubes that share an edge are connected as well) and vertex (26 neighbors, cubes that share a vertex are connected as well).")
 	flag.Parse()
 
+	if shard < 0 || shard >= shards {
+		panic("Shard should be at least 0 and smaller than the number of shards")
+	}
+	if resume && checkpointFileName == "" {
+		panic("A checkpoint file name is needed to resume")
+	}
+	if dimensions < 1 || dimensions > MaxDimensions {
+		panic(fmt.Sprintf("The number of dimensions should be at least 1 and at most %d", MaxDimensions))
+	}
+	if imagePath != "" && dimensions > 3 {
+		panic("Images can only be generated for up to 3 dimensions")
+	}
+	if symmetry && dimensions != 3 {
+		panic("Point groups can only be printed for 3 dimensions")
+	}
+
+	equivalence, err := EquivalenceFromString(equivalenceName)
+	if err != nil {
+		panic(err)
+	}
+
+	adjacency, err := AdjacencyFromString(adjacencyName)
+	if err != nil {
+		panic(err)
+	}
+
+	if format == "" {
+		format = store.DetectFormat(fileName)
+	}
+	if _, err := store.NewStore(format); err != nil {
+		panic(err)
+	}
+
+	// the address is bound before anything is grown, so a run doesn't fail after it has started
+	var listener net.Listener
+	if httpAddress != "" {
+		if listener, err = net.Listen("tcp", httpAddress); err != nil {
+			panic(err)
+		}
+	}
+
+	var box *Box
+	if boxString != "" {
+		if box, err = BoxFromString(boxString); err != nil {
+			panic(err)
+		}
+	}
+
 	var NewShapes func() Shapes
+	canonical := false
 	if method == "DefaultMap" {
 		NewShapes = NewShapesDefaultMap
 	} else if method == "LongestStraightMap" {
 		NewShapes = NewShapesLongestStraightMap
+	} else if method == "Concurrent" {
+		NewShapes = NewShapesConcurrent
+	} else if method == "Disk" {
+		if memo && memoSize <= 0 {
+			panic("The Disk method can't remember all polycubes with -memo, specify a -memo-size or disable -memo")
+		}
+		NewShapes = NewShapesDiskWithBudget(int64(memoryBudget) << 20)
+	} else if method == "Canonical" {
+		NewShapes = NewShapesDefaultMap
+		canonical = true
 	} else {
 		panic("Unknown method specified")
 	}
+	NewShapes = NewShapesWithEquivalence(NewShapes, equivalence)
+
+	if headers, err := store.ReadHeaders(fileName, format); err == nil {
+		fileDimensions, err := store.Dimensions(headers)
+		if err != nil {
+			panic(err)
+		}
+		if fileDimensions != dimensions {
+			panic(fmt.Sprintf("File contains shapes with %d dimensions but %d dimensions are requested", fileDimensions, dimensions))
+		}
+		fileAdjacency, err := store.HeaderAdjacency(headers)
+		if err != nil {
+			panic(err)
+		}
+		if fileAdjacency != adjacency {
+			panic(fmt.Sprintf("File contains shapes with %v adjacency but %v adjacency is requested", fileAdjacency, adjacency))
+		}
+	}
 
 	var shapes Shapes
 	shapes = NewShapes()
-	var err error
-	if shapes, err = store.ReadText(fileName, shapes); err != nil {
+	if resume {
+		// the file can already contain shapes that are grown before the checkpoint, only read the shapes the
+		// interrupted run started from
+		var c *checkpoint
+		if c, err = readCheckpoint(checkpointFileName); err != nil {
+			panic(err)
+		}
+		err = store.ScanShapes(fileName, format, func(s *Shape) error {
+			if s.Size() <= c.ParentSize {
+				shapes.Add(*s)
+			}
+			return nil
+		})
+	} else {
+		shapes, err = store.ReadShapes(fileName, format, shapes)
+	}
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			panic(err)
+		}
 		shapes = NewShapes()
 	}
+	if box != nil {
+		shapes = fitInBox(shapes, box)
+	}
 	if shapes.Len() == 0 {
-		shapes.Add(*NewShape(NewShapes))
+		monomer := NewShapeWithDimensions(NewShapes, dimensions)
+		monomer.SetAdjacency(adjacency)
+		shapes.Add(*monomer)
 	}
+	pool := NewPool(workers)
+	pool.SetBox(box)
+	found, parents := splitShards(pool, shapes, NewShapes, ShapeSize(maxSize), shard, shards)
+	defer CloseShapes(found)
+	outputFileName := shardFileName(fileName, shard, shards)
+	headers := append(shardHeaders(shard, shards), store.DimensionsHeaders(dimensions)...)
+	headers = append(headers, store.AdjacencyHeaders(adjacency)...)
+	headers = append(headers, store.Header{Key: store.METHOD_HEADER, Value: method}, store.Header{Key: store.MAX_SIZE_HEADER, Value: strconv.Itoa(maxSize)})
 
-	currentMaxSize := shapes.MaxSize()
+	if progressInterval > 0 || listener != nil {
+		p := newProgress(ShapeSize(maxSize))
+		IterateAll(found, func(shape *Shape) bool {
+			p.Found(shape)
+			return true
+		})
+		pool.SetObserver(p)
+		// with only a few parents the number of parents that are done says little about the progress, with checkpoints
+		// the parents are split into roots when the checkpoint is read so the parents are the same for every run
+		if checkpointFileName == "" {
+			parents = splitRoots(pool, equivalence, found, parents, ShapeSize(maxSize))
+		}
+		if progressInterval > 0 {
+			p.print(os.Stderr, progressInterval)
+		}
+		if listener != nil {
+			p.serve(listener)
+		}
+	}
 
-	wg := sync.WaitGroup{}
-	currentShapes := shapes.GetAllWithSize(currentMaxSize)
-	wg.Add(len(currentShapes))
-	c := make(chan Shapes, len(currentShapes))
-	for _, shape := range currentShapes {
-		shape.SetNewShapesMethod(NewShapes)
-		go func(shape *Shape) {
-			shape.KeepGrowing(ShapeSize(maxSize), c)
-			wg.Done()
-		}(shape)
+	if levels {
+		if countOnly || canonical || checkpointFileName != "" {
+			panic("Growing level by level is not supported by the Canonical method, -count-only and checkpoints")
+		}
+		counter := growLevels(pool, equivalence, found, parents, ShapeSize(maxSize), outputFileName, format, imagePath, headers)
+		if box != nil {
+			printCounts(counter)
+		}
+		if symmetry {
+			printPointGroups(counter)
+		}
+		fmt.Printf("Found %d shapes with size %d\n", counter.Count(ShapeSize(maxSize)), maxSize)
+		return
 	}
-	wg.Wait()
-	close(c)
-	for s := range c {
-		shapes.Merge(s)
+
+	if checkpointFileName != "" {
+		if !countOnly && !canonical {
+			panic("Checkpoints are only supported by the Canonical method and -count-only")
+		}
+		if countOnly {
+			outputFileName = ""
+		}
+		counter, err := growWithCheckpoints(pool, equivalence, found, parents, ShapeSize(maxSize), outputFileName, format, headers, checkpointFileName, checkpointInterval, resume, shard, shards)
+		if err != nil {
+			panic(err)
+		}
+		if countOnly || box != nil {
+			printCounts(counter)
+		}
+		if symmetry {
+			printPointGroups(counter)
+		}
+		if !countOnly {
+			fmt.Printf("Found %d shapes with size %d\n", counter.Count(ShapeSize(maxSize)), maxSize)
+		}
+		return
 	}
 
-	if fileName != "" {
-		store.WriteText(shapes, fileName)
+	if countOnly {
+		counter := countCanonical(pool, equivalence, found, parents, ShapeSize(maxSize))
+		printCounts(counter)
+		if symmetry {
+			printPointGroups(counter)
+		}
+		return
+	}
+
+	if canonical {
+		counter := growCanonical(pool, equivalence, found, parents, ShapeSize(maxSize), outputFileName, format, imagePath, headers)
+		if box != nil {
+			printCounts(counter)
+		}
+		if symmetry {
+			printPointGroups(counter)
+		}
+		fmt.Printf("Found %d shapes with size %d\n", counter.Count(ShapeSize(maxSize)), maxSize)
+		return
+	}
+
+	shapes = found
+	var visited *Visited
+	if memo {
+		visited = NewVisited(memoSize)
+		pool.SetVisited(visited)
+	}
+	if len(parents) > 0 {
+		grown := pool.KeepGrowing(parents, ShapeSize(maxSize))
+		shapes.Merge(grown)
+		CloseShapes(grown)
+	}
+
+	if outputFileName != "" {
+		store.WriteShapes(shapes, outputFileName, format, headers...)
 	}
 
 	if imagePath != "" {
-		wg = sync.WaitGroup{}
+		wg := sync.WaitGroup{}
 		for size := ShapeSize(1); size <= ShapeSize(maxSize); size++ {
 			counter := 1
-			for _, shape := range shapes.GetAllWithSize(size) {
+			shapes.Iterate(size, func(shape *Shape) bool {
 				wg.Add(1)
 				go func(shape *Shape, size ShapeSize, counter int) {
 					store.WriteImage(shape, 1024, 1024, fmt.Sprintf("%s/shape_%02d_%015d.png", imagePath, size, counter), 0.85)
 					wg.Done()
 				}(shape, size, counter)
 				counter += 1
-			}
+				return true
+			})
 		}
 		wg.Wait()
 	}
 
-	fmt.Printf("Found %d shapes with size %d\n", len(shapes.GetAllWithSize(ShapeSize(maxSize))), maxSize)
+	if symmetry || box != nil {
+		counter := NewCounter(shapes.Equivalence())
+		IterateAll(shapes, func(shape *Shape) bool {
+			counter.Add(shape)
+			return true
+		})
+		if box != nil {
+			printCounts(counter)
+		}
+		if symmetry {
+			printPointGroups(counter)
+		}
+	}
+
+	if visited != nil {
+		printVisited(visited)
+	}
+	fmt.Printf("Found %d shapes with size %d\n", CountWithSize(shapes, ShapeSize(maxSize)), maxSize)
+}
+
+// shapeWriter writes shapes to the file and image path as soon as they are found and counts them
+type shapeWriter struct {
+	w         store.Store
+	imagePath string
+	counter   *Counter
+	wg        sync.WaitGroup
+}
+
+func newShapeWriter(equivalence Equivalence, fileName string, format string, imagePath string, headers []store.Header) *shapeWriter {
+	result := &shapeWriter{imagePath: imagePath, counter: NewCounter(equivalence)}
+	if fileName != "" {
+		var err error
+		if result.w, err = store.Create(fileName, format, equivalence, headers...); err != nil {
+			panic(err)
+		}
+	}
+
+	return result
+}
+
+func (w *shapeWriter) write(shape *Shape) {
+	w.counter.Add(shape)
+	if w.w != nil {
+		if err := w.w.Write(shape); err != nil {
+			panic(err)
+		}
+	}
+	if w.imagePath != "" {
+		w.wg.Add(1)
+		go func(shape *Shape, size ShapeSize, counter int) {
+			store.WriteImage(shape, 1024, 1024, fmt.Sprintf("%s/shape_%02d_%015d.png", w.imagePath, size, counter), 0.85)
+			w.wg.Done()
+		}(shape, shape.Size(), w.counter.Count(shape.Size()))
+	}
+}
+
+// flush writes the buffered shapes to the file
+func (w *shapeWriter) flush() {
+	if w.w != nil {
+		if err := w.w.Flush(); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// close waits for the images and closes the file, returns a counter with all the shapes that are written
+func (w *shapeWriter) close() *Counter {
+	w.wg.Wait()
+	if w.w != nil {
+		if err := w.w.Close(); err != nil {
+			panic(err)
+		}
+	}
+
+	return w.counter
+}
+
+// growCanonical grows the parents until maxSize is reached using canonical augmentation. The found shapes and every shape
+// that is grown are written to the file and image path as soon as they are found, so the shapes are never collected in
+// memory. Returns a counter with all the shapes, including the found shapes.
+func growCanonical(pool *Pool, equivalence Equivalence, found Shapes, parents []*Shape, maxSize ShapeSize, fileName string, format string, imagePath string, headers []store.Header) *Counter {
+	w := newShapeWriter(equivalence, fileName, format, imagePath, headers)
+
+	IterateAll(found, func(shape *Shape) bool {
+		w.write(shape)
+		return true
+	})
+
+	c := make(chan *Shape, 1024)
+	go func() {
+		pool.KeepGrowingCanonical(parents, equivalence, maxSize, func(worker int, s *Shape) {
+			c <- s
+		})
+		close(c)
+	}()
+	for shape := range c {
+		w.write(shape)
+	}
+
+	return w.close()
+}
+
+// growLevels grows the parents one size at a time until maxSize is reached, every shape of a size is grown once. Only
+// the shapes of one size are kept in memory, when all shapes of a size are grown they are written to the file and image
+// path before the next size is grown. Returns a counter with all the shapes, including the found shapes.
+func growLevels(pool *Pool, equivalence Equivalence, found Shapes, parents []*Shape, maxSize ShapeSize, fileName string, format string, imagePath string, headers []store.Header) *Counter {
+	w := newShapeWriter(equivalence, fileName, format, imagePath, headers)
+
+	IterateAll(found, func(shape *Shape) bool {
+		w.write(shape)
+		return true
+	})
+	w.flush()
+
+	level := parents
+	for len(level) > 0 && level[0].Size() < maxSize {
+		shapes := pool.GrowLevel(level)
+		level = make([]*Shape, 0, shapes.Len())
+		shapes.Iterate(shapes.MaxSize(), func(shape *Shape) bool {
+			w.write(shape)
+			level = append(level, shape)
+			return true
+		})
+		CloseShapes(shapes)
+		w.flush()
+	}
+
+	return w.close()
+}
+
+// countCanonical counts the found shapes and the shapes that can be grown from the parents until maxSize is reached
+// using canonical augmentation. Only the counters are kept in memory.
+func countCanonical(pool *Pool, equivalence Equivalence, found Shapes, parents []*Shape, maxSize ShapeSize) *Counter {
+	result := NewCounter(equivalence)
+	IterateAll(found, func(shape *Shape) bool {
+		result.Add(shape)
+		return true
+	})
+
+	counters := make([]*Counter, pool.Workers())
+	for i := range counters {
+		counters[i] = NewCounter(equivalence)
+	}
+	pool.KeepGrowingCanonical(parents, equivalence, maxSize, func(worker int, s *Shape) {
+		counters[worker].Add(s)
+	})
+	for _, counter := range counters {
+		result.Merge(counter)
+	}
+
+	return result
+}
+
+// fitInBox returns the shapes that fit in the box, shapes is closed
+func fitInBox(shapes Shapes, box *Box) Shapes {
+	result := shapes.Filter(box.Fits)
+	CloseShapes(shapes)
+
+	return result
+}
+
+// parents returns the shapes with the maximum size in shapes, these are the shapes new shapes are grown from
+func parents(shapes Shapes, newShapes func() Shapes) []*Shape {
+	result := make([]*Shape, 0)
+	shapes.Iterate(shapes.MaxSize(), func(shape *Shape) bool {
+		if newShapes != nil {
+			shape.SetNewShapesMethod(newShapes)
+		}
+		result = append(result, shape)
+		return true
+	})
+
+	return result
+}
+
+func printCounts(counter *Counter) {
+	fmt.Printf("%4s %20s %20s %20s\n", "n", "free", "one-sided", "fixed")
+	for size := ShapeSize(1); size <= counter.MaxSize(); size++ {
+		free, oneSided, fixed := counter.Exact(size)
+		fmt.Printf("%4d %20s %20s %20s\n", size, free.RatString(), oneSided.RatString(), fixed.RatString())
+	}
+}
+
+func printVisited(visited *Visited) {
+	hits, misses := visited.Hits(), visited.Misses()
+	saved := 0.0
+	if hits+misses > 0 {
+		saved = 100 * float64(hits) / float64(hits+misses)
+	}
+	fmt.Printf("Grew %d shapes and skipped %d shapes that were already grown (%.1f%% saved), %d shapes remembered, %d forgotten\n", misses, hits, saved, visited.Len(), visited.Evictions())
+}
+
+func printPointGroups(counter *Counter) {
+	fmt.Printf("%4s %11s %20s\n", "n", "point group", counter.Equivalence())
+	for size := ShapeSize(1); size <= counter.MaxSize(); size++ {
+		counts := counter.PointGroupCounts(size)
+		for _, pointGroup := range PointGroups {
+			if count, ok := counts[pointGroup]; ok {
+				fmt.Printf("%4d %11s %20d\n", size, pointGroup, count)
+			}
+		}
+	}
 }
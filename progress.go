@@ -0,0 +1,134 @@
+package main
+
+import (
+	"expvar"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	. "github.com/munnik/cubes/shape"
+)
+
+// progress keeps track of the shapes that are found and the parents that are done while growing shapes, it is the
+// observer of the pool
+type progress struct {
+	start       time.Time
+	found       []int64 // number of shapes found per size, updated atomically
+	parents     int64
+	parentsDone int64
+}
+
+// progressReport is a snapshot of the progress, it is printed periodically and served as JSON
+type progressReport struct {
+	Elapsed         string              `json:"elapsed"`
+	Found           map[ShapeSize]int64 `json:"found"`
+	Total           int64               `json:"total"`
+	Parents         int64               `json:"parents"`
+	ParentsDone     int64               `json:"parentsDone"`
+	ShapesPerSecond float64             `json:"shapesPerSecond"`
+	ETA             string              `json:"eta"`
+	HeapAlloc       uint64              `json:"heapAlloc"`
+	Goroutines      int                 `json:"goroutines"`
+}
+
+func newProgress(maxSize ShapeSize) *progress {
+	return &progress{
+		start: time.Now(),
+		found: make([]int64, maxSize+1),
+	}
+}
+
+// Start resets the parents, every time the pool starts growing new parents
+func (p *progress) Start(parents []*Shape) {
+	atomic.StoreInt64(&p.parents, int64(len(parents)))
+	atomic.StoreInt64(&p.parentsDone, 0)
+}
+
+func (p *progress) Found(s *Shape) {
+	if int(s.Size()) < len(p.found) {
+		atomic.AddInt64(&p.found[s.Size()], 1)
+	}
+}
+
+func (p *progress) Done(parent *Shape) {
+	atomic.AddInt64(&p.parentsDone, 1)
+}
+
+// report returns a snapshot of the progress
+func (p *progress) report() progressReport {
+	elapsed := time.Since(p.start)
+	result := progressReport{
+		Elapsed:     elapsed.Round(time.Second).String(),
+		Found:       make(map[ShapeSize]int64),
+		Parents:     atomic.LoadInt64(&p.parents),
+		ParentsDone: atomic.LoadInt64(&p.parentsDone),
+		Goroutines:  runtime.NumGoroutine(),
+	}
+	for size := range p.found {
+		if count := atomic.LoadInt64(&p.found[size]); count > 0 {
+			result.Found[ShapeSize(size)] = count
+			result.Total += count
+		}
+	}
+
+	if elapsed > 0 {
+		result.ShapesPerSecond = float64(result.Total) / elapsed.Seconds()
+	}
+
+	// the parents don't take the same time to grow, so the estimate is only rough
+	if result.ParentsDone > 0 && result.ParentsDone < result.Parents {
+		remaining := time.Duration(float64(elapsed) * float64(result.Parents-result.ParentsDone) / float64(result.ParentsDone))
+		result.ETA = remaining.Round(time.Second).String()
+	}
+
+	var memStats runtime.MemStats
+	runtime.ReadMemStats(&memStats)
+	result.HeapAlloc = memStats.HeapAlloc
+
+	return result
+}
+
+func (r progressReport) String() string {
+	found := make([]string, 0, len(r.Found))
+	for size := ShapeSize(1); len(found) < len(r.Found); size++ {
+		if count, ok := r.Found[size]; ok {
+			found = append(found, fmt.Sprintf("%d:%d", size, count))
+		}
+	}
+	eta := r.ETA
+	if eta == "" {
+		eta = "unknown"
+	}
+
+	return fmt.Sprintf("%s: parents %d/%d, found %d (%s), %.0f shapes/s, ETA %s, heap %d MiB, %d goroutines",
+		r.Elapsed, r.ParentsDone, r.Parents, r.Total, strings.Join(found, " "), r.ShapesPerSecond, eta, r.HeapAlloc>>20, r.Goroutines)
+}
+
+// print writes a report to w every interval in the background
+func (p *progress) print(w io.Writer, interval time.Duration) {
+	go func() {
+		for range time.Tick(interval) {
+			fmt.Fprintln(w, p.report())
+		}
+	}()
+}
+
+// serve publishes the report as the expvar progress and serves all expvars as JSON on /debug/vars with the listener in
+// the background. The listener is bound before growing starts so an address that is in use is reported right away, if
+// serving stops later the error is printed to stderr and growing continues.
+func (p *progress) serve(listener net.Listener) {
+	expvar.Publish("progress", expvar.Func(func() any {
+		return p.report()
+	}))
+	go func() {
+		if err := http.Serve(listener, nil); err != nil {
+			fmt.Fprintf(os.Stderr, "serving progress on %s stopped: %v\n", listener.Addr(), err)
+		}
+	}()
+}
@@ -0,0 +1,60 @@
+package shape
+
+import "fmt"
+
+// Adjacency defines which cubes are neighbors, the cubes of a shape are connected through neighbors
+type Adjacency int
+
+const (
+	Face   = Adjacency(0) // cubes are neighbors if they share a face, 6 neighbors in 3 dimensions, OEIS A000162
+	Edge   = Adjacency(1) // cubes are neighbors if they share an edge or a face, 18 neighbors in 3 dimensions
+	Vertex = Adjacency(2) // cubes are neighbors if they share a vertex, an edge or a face, 26 neighbors in 3 dimensions
+)
+
+func (a Adjacency) String() string {
+	switch a {
+	case Face:
+		return "face"
+	case Edge:
+		return "edge"
+	case Vertex:
+		return "vertex"
+	}
+
+	return fmt.Sprintf("unknown adjacency %d", int(a))
+}
+
+func AdjacencyFromString(s string) (Adjacency, error) {
+	for _, a := range []Adjacency{Face, Edge, Vertex} {
+		if s == a.String() {
+			return a, nil
+		}
+	}
+
+	return 0, fmt.Errorf("unknown adjacency %s", s)
+}
+
+// returns the maximum number of axes in which the coordinates of two neighbors differ
+func (a Adjacency) axes(dimensions int) int {
+	switch a {
+	case Face:
+		return 1
+	case Edge:
+		if dimensions < 2 {
+			return dimensions
+		}
+		return 2
+	}
+
+	return dimensions
+}
+
+// NeighborDeltas returns the differences between a coordinate and its neighbors in the specified number of dimensions
+func (a Adjacency) NeighborDeltas(dimensions int) []Coord {
+	result := make([]Coord, 0, len(neighborOffsets[a][dimensions]))
+	for _, offset := range neighborOffsets[a][dimensions] {
+		result = append(result, (pack(Coord{}) + offset).unpack())
+	}
+
+	return result
+}
@@ -0,0 +1,130 @@
+package shape_test
+
+import (
+	"testing"
+
+	. "github.com/munnik/cubes/shape"
+)
+
+func TestAdjacencyFromString(t *testing.T) {
+	for _, a := range []Adjacency{Face, Edge, Vertex} {
+		result, err := AdjacencyFromString(a.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result != a {
+			t.Fatalf("Expected %v but got %v", a, result)
+		}
+	}
+
+	if _, err := AdjacencyFromString("corner"); err == nil {
+		t.Fatalf("Expected an error for an unknown adjacency")
+	}
+}
+
+func TestNeighborDeltas(t *testing.T) {
+	for _, test := range []struct {
+		adjacency  Adjacency
+		dimensions int
+		expected   int
+	}{
+		{Face, 3, 6},
+		{Edge, 3, 18},
+		{Vertex, 3, 26},
+		{Face, 2, 4},
+		{Edge, 2, 8},
+		{Vertex, 2, 8},
+		{Edge, 4, 32},
+		{Vertex, 4, 80},
+	} {
+		if n := len(test.adjacency.NeighborDeltas(test.dimensions)); n != test.expected {
+			t.Fatalf("Expected %d %v neighbors in %d dimensions but got %d", test.expected, test.adjacency, test.dimensions, n)
+		}
+	}
+
+	c := &Coord{1, 2, 3}
+	if _, ok := c.Neighbors()[Coord{1, 2, 4}]; !ok || len(c.Neighbors()) != 6 {
+		t.Fatalf("Expected 6 neighbors of %v including [1 2 4] but got %v", c, c.Neighbors())
+	}
+	if _, ok := c.NeighborsWithAdjacency(Vertex, 3)[Coord{0, 1, 2}]; !ok {
+		t.Fatalf("Expected [0 1 2] to be a vertex neighbor of %v", c)
+	}
+}
+
+func TestAdjacencyAddCube(t *testing.T) {
+	var f func() Shapes
+	face := NewShape(f)
+	if _, err := face.AddCube(&Coord{1, 1, 0}); err == nil {
+		t.Fatalf("Expected [1 1 0] not to be a face neighbor of %v", face)
+	}
+
+	edge := NewShape(f)
+	edge.SetAdjacency(Edge)
+	s := edge.MustAddCube(&Coord{1, 1, 0})
+	if s.Adjacency() != Edge || !s.IsConnected() {
+		t.Fatalf("Expected a connected shape with edge adjacency but got %v", s)
+	}
+	if _, err := s.AddCube(&Coord{2, 1, 1}); err != nil {
+		t.Fatalf("Expected [2 1 1] to be an edge neighbor of %v: %v", s, err)
+	}
+	if _, err := s.AddCube(&Coord{2, 2, 1}); err == nil {
+		t.Fatalf("Expected [2 2 1] not to be an edge neighbor of %v", s)
+	}
+
+	vertex := NewShape(f)
+	vertex.SetAdjacency(Vertex)
+	if _, err := vertex.MustAddCube(&Coord{1, 1, 0}).AddCube(&Coord{2, 2, 1}); err != nil {
+		t.Fatalf("Expected [2 2 1] to be a vertex neighbor: %v", err)
+	}
+}
+
+func TestAdjacencyCounts(t *testing.T) {
+	for _, test := range []struct {
+		adjacency   Adjacency
+		dimensions  int
+		equivalence Equivalence
+		expected    []int
+	}{
+		{Edge, 3, Free, []int{1, 2, 8, 64}},
+		{Edge, 3, Fixed, []int{1, 9, 113, 1623}},
+		{Vertex, 3, Free, []int{1, 3, 14, 165}},
+		{Vertex, 3, Fixed, []int{1, 13, 237, 4995}},
+		// the polyplets, OEIS A030222 and A006770
+		{Vertex, 2, Free, []int{1, 2, 5, 22, 94, 524}},
+		{Vertex, 2, Fixed, []int{1, 4, 20, 110, 638, 3832}},
+	} {
+		counts := make([]int, len(test.expected))
+		counts[0] = 1
+		s := NewShapeWithDimensions(NewShapesDefaultMap, test.dimensions)
+		s.SetAdjacency(test.adjacency)
+		s.KeepGrowingCanonical(test.equivalence, ShapeSize(len(test.expected)), func(s *Shape) {
+			counts[s.Size()-1]++
+		})
+
+		for i := range test.expected {
+			if counts[i] != test.expected[i] {
+				t.Fatalf("Expected %d %v shapes with size %d and %v adjacency in %d dimensions but got %d", test.expected[i], test.equivalence, i+1, test.adjacency, test.dimensions, counts[i])
+			}
+		}
+	}
+}
+
+func TestPoolKeepGrowingAdjacency(t *testing.T) {
+	expected := []int{1, 3, 14, 165}
+
+	for _, newShapes := range []func() Shapes{NewShapesDefaultMap, NewShapesDisk} {
+		s := NewShape(NewShapesWithEquivalence(newShapes, Free))
+		s.SetAdjacency(Vertex)
+		shapes := NewPool(2).KeepGrowing([]*Shape{s}, ShapeSize(len(expected)))
+		for i := range expected {
+			if len(shapes.GetAllWithSize(ShapeSize(i+1))) != expected[i] {
+				t.Fatalf("Expected %d shapes with size %d but got %d", expected[i], i+1, len(shapes.GetAllWithSize(ShapeSize(i+1))))
+			}
+		}
+		for _, shape := range shapes.GetAllWithSize(ShapeSize(len(expected))) {
+			if shape.Adjacency() != Vertex {
+				t.Fatalf("Expected shapes with vertex adjacency but got %v", shape.Adjacency())
+			}
+		}
+	}
+}
@@ -6,4 +6,8 @@ const (
 	XAxis = Axis(0)
 	YAxis = Axis(1)
 	ZAxis = Axis(2)
+	WAxis = Axis(3)
 )
+
+// MaxDimensions is the maximum number of dimensions of a shape, every axis of a coordinate uses packedBits bits
+const MaxDimensions = 4
@@ -0,0 +1,86 @@
+package shape
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Box restricts the shapes to shapes that fit in a box of Width × Height × Depth cubes in some orientation. A shape
+// that doesn't fit has no shapes grown from it that fit, because adding a cube never makes the bounding box smaller.
+type Box struct {
+	Width  int
+	Height int
+	Depth  int
+}
+
+// BoxFromString parses a box written as WxHxD, for example 4x4x2
+func BoxFromString(s string) (*Box, error) {
+	parts := strings.Split(s, "x")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("box %q should be written as WxHxD", s)
+	}
+
+	dimensions := make([]int, 0, len(parts))
+	for _, part := range parts {
+		dimension, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil {
+			return nil, fmt.Errorf("box %q should be written as WxHxD: %w", s, err)
+		}
+		if dimension < 1 {
+			return nil, fmt.Errorf("box %q should have dimensions of at least 1", s)
+		}
+		dimensions = append(dimensions, dimension)
+	}
+
+	return &Box{Width: dimensions[0], Height: dimensions[1], Depth: dimensions[2]}, nil
+}
+
+func (b *Box) String() string {
+	return fmt.Sprintf("%dx%dx%d", b.Width, b.Height, b.Depth)
+}
+
+// Fits returns true if the shape fits in the box after rotating it, every shape fits in a nil box
+func (b *Box) Fits(s *Shape) bool {
+	if b == nil {
+		return true
+	}
+
+	// a box has 3 dimensions, a shape with more dimensions only fits if it is flat in the other dimensions
+	boundingBox := s.BoundingBox()
+	extents := make([]int, 0, MaxDimensions)
+	for axis := range boundingBox.Min {
+		extents = append(extents, boundingBox.Max[axis]-boundingBox.Min[axis]+1)
+	}
+	dimensions := []int{b.Width, b.Height, b.Depth}
+	for len(dimensions) < len(extents) {
+		dimensions = append(dimensions, 1)
+	}
+	sort.Ints(extents)
+	sort.Ints(dimensions)
+
+	for i := range extents {
+		if extents[i] > dimensions[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// returns the shapes that fit in the box, shapes is filtered in place
+func (b *Box) filter(shapes []*Shape) []*Shape {
+	if b == nil {
+		return shapes
+	}
+
+	result := shapes[:0]
+	for _, s := range shapes {
+		if b.Fits(s) {
+			result = append(result, s)
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,81 @@
+package shape_test
+
+import (
+	"sync"
+	"testing"
+
+	. "github.com/munnik/cubes/shape"
+)
+
+func TestBoxFromString(t *testing.T) {
+	box, err := BoxFromString("4x3x2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *box != (Box{Width: 4, Height: 3, Depth: 2}) || box.String() != "4x3x2" {
+		t.Fatalf("Expected box 4x3x2 but got %v", box)
+	}
+
+	for _, s := range []string{"", "4x3", "4x3x2x1", "4xax2", "4x0x2"} {
+		if _, err := BoxFromString(s); err == nil {
+			t.Fatalf("Expected an error for box %q", s)
+		}
+	}
+}
+
+func TestBoxFits(t *testing.T) {
+	var f func() Shapes
+	straight := NewShape(f).MustAddCube(&Coord{1, 0, 0}).MustAddCube(&Coord{2, 0, 0})
+	corner := NewShape(f).MustAddCube(&Coord{1, 0, 0}).MustAddCube(&Coord{0, 0, 1})
+
+	for _, test := range []struct {
+		box      Box
+		shape    *Shape
+		expected bool
+	}{
+		{Box{3, 1, 1}, straight, true},
+		{Box{1, 1, 3}, straight, true},
+		{Box{2, 2, 2}, straight, false},
+		{Box{2, 2, 1}, corner, true},
+		{Box{1, 2, 2}, corner, true},
+		{Box{3, 1, 1}, corner, false},
+	} {
+		if test.box.Fits(test.shape) != test.expected {
+			t.Fatalf("Expected fitting %v in box %v to be %t", test.shape, test.box, test.expected)
+		}
+	}
+
+	var box *Box
+	if !box.Fits(straight) {
+		t.Fatalf("Expected every shape to fit in a nil box")
+	}
+}
+
+func TestPoolBox(t *testing.T) {
+	// flat polycubes are the free polyominoes
+	expected := []int{1, 1, 2, 5, 12, 35, 108}
+
+	pool := NewPool(2)
+	pool.SetBox(&Box{Width: 7, Height: 7, Depth: 1})
+
+	mu := sync.Mutex{}
+	counter := NewCounter(OneSided)
+	counter.Add(NewShape(nil))
+	pool.KeepGrowingCanonical([]*Shape{NewShape(nil)}, OneSided, ShapeSize(len(expected)), func(worker int, s *Shape) {
+		mu.Lock()
+		counter.Add(s)
+		mu.Unlock()
+	})
+	for i := range expected {
+		if counter.Free(ShapeSize(i+1)) != expected[i] {
+			t.Fatalf("Expected %d free shapes with size %d but got %d", expected[i], i+1, counter.Free(ShapeSize(i+1)))
+		}
+	}
+
+	shapes := pool.KeepGrowing([]*Shape{NewShape(NewShapesDefaultMap)}, ShapeSize(len(expected)))
+	for i := range expected {
+		if len(shapes.GetAllWithSize(ShapeSize(i+1))) != expected[i] {
+			t.Fatalf("Expected %d shapes with size %d but got %d", expected[i], i+1, len(shapes.GetAllWithSize(ShapeSize(i+1))))
+		}
+	}
+}
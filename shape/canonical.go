@@ -0,0 +1,83 @@
+package shape
+
+import "sort"
+
+// CanonicalParent returns the shape that is left after removing the canonical cube from s. The canonical cube is the cube
+// with the highest score value, in the canonical transformation under the equivalence, that can be removed without
+// disconnecting the shape. The result is returned in the canonical transformation under the equivalence. A shape with a
+// single cube has no parent and nil is returned.
+func (s *Shape) CanonicalParent(equivalence Equivalence) *Shape {
+	if s.Size() <= 1 {
+		return nil
+	}
+
+	canonical := s.Canonical(equivalence)
+	size := int(canonical.Size())
+
+	coords := canonical.Coords()
+	value := func(c Coord) int {
+		result := 0
+		for _, axis := range []Axis{WAxis, ZAxis, YAxis, XAxis} {
+			result = result*size + c[axis]
+		}
+		return result
+	}
+	sort.Slice(coords, func(i, j int) bool {
+		return value(coords[i]) > value(coords[j])
+	})
+
+	for _, c := range coords {
+		parent := canonical.MustRemoveCube(&c)
+		if parent.IsConnected() {
+			return parent.Canonical(equivalence)
+		}
+	}
+
+	// every connected shape has at least two cubes that can be removed, this can't be reached
+	panic("shape has no removable cube")
+}
+
+// CanonicalChildren returns all shapes with one cube added to s for which s is the canonical parent under the
+// equivalence. Every child is returned once, in the canonical transformation under the equivalence.
+func (s *Shape) CanonicalChildren(equivalence Equivalence) []*Shape {
+	parent := s.Canonical(equivalence)
+	parentScore := parent.Score()
+
+	seen := make(map[Score]struct{})
+	result := make([]*Shape, 0)
+	for _, c := range parent.candidateCoords() {
+		child := parent.MustAddCube(&c).Canonical(equivalence)
+		if _, ok := seen[child.Score()]; ok {
+			continue
+		}
+		seen[child.Score()] = struct{}{}
+
+		if child.CanonicalParent(equivalence).Score() == parentScore {
+			result = append(result, child)
+		}
+	}
+
+	return result
+}
+
+// KeepGrowingCanonical calls emit for every unique shape under the equivalence that can be grown from s until the shapes
+// reach the specified maxSize, s itself is not emitted. Because a shape is only grown from its canonical parent every
+// shape is emitted exactly once and no set of all the shapes found has to be kept in memory. emit is called from the
+// calling goroutine.
+func (s *Shape) KeepGrowingCanonical(equivalence Equivalence, maxSize ShapeSize, emit func(*Shape)) {
+	s.KeepGrowingCanonicalInBox(equivalence, maxSize, nil, emit)
+}
+
+// KeepGrowingCanonicalInBox works like KeepGrowingCanonical but only grows shapes that fit in the box, a nil box
+// doesn't restrict the shapes. Every shape that fits in the box is grown because the canonical parent of a shape is
+// smaller than the shape and fits in the box as well.
+func (s *Shape) KeepGrowingCanonicalInBox(equivalence Equivalence, maxSize ShapeSize, box *Box, emit func(*Shape)) {
+	if s.Size() >= maxSize {
+		return
+	}
+
+	for _, child := range box.filter(s.CanonicalChildren(equivalence)) {
+		emit(child)
+		child.KeepGrowingCanonicalInBox(equivalence, maxSize, box, emit)
+	}
+}
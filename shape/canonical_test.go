@@ -0,0 +1,36 @@
+package shape_test
+
+import (
+	"testing"
+
+	. "github.com/munnik/cubes/shape"
+)
+
+func TestKeepGrowingCanonical(t *testing.T) {
+	expected := []int{1, 1, 2, 8, 29, 166, 1023}
+
+	counts := make([]int, len(expected))
+	counts[0] = 1
+	NewShape(NewShapesDefaultMap).KeepGrowingCanonical(OneSided, ShapeSize(len(expected)), func(s *Shape) {
+		counts[s.Size()-1]++
+	})
+
+	for i := range expected {
+		if counts[i] != expected[i] {
+			t.Fatalf("Expected %d shapes with size %d but got %d", expected[i], i+1, counts[i])
+		}
+	}
+}
+
+func TestCanonicalParent(t *testing.T) {
+	var f func() Shapes
+	s := NewShape(f).MustAddCube(&Coord{1, 0, 0}).MustAddCube(&Coord{2, 0, 0}).MustAddCube(&Coord{1, 1, 0})
+	parent := s.CanonicalParent(OneSided)
+	if parent.Size() != 3 || !parent.IsConnected() {
+		t.Fatalf("Expected a connected parent with 3 cubes but got %v", parent)
+	}
+
+	if NewShape(f).CanonicalParent(OneSided) != nil {
+		t.Fatalf("Expected a single cube to have no parent")
+	}
+}
@@ -6,42 +6,54 @@ import (
 	"strings"
 )
 
-type Coord [3]int
+// Coord is a coordinate with an integer for every axis, shapes with less than MaxDimensions dimensions only use the
+// first axes and the other axes are 0
+type Coord [MaxDimensions]int
 
 func (c Coord) Left() Coord {
-	return Coord{c[XAxis] + 1, c[YAxis], c[ZAxis]}
+	c[XAxis]++
+	return c
 }
 
 func (c Coord) Right() Coord {
-	return Coord{c[XAxis] - 1, c[YAxis], c[ZAxis]}
+	c[XAxis]--
+	return c
 }
 
 func (c Coord) Above() Coord {
-	return Coord{c[XAxis], c[YAxis] + 1, c[ZAxis]}
+	c[YAxis]++
+	return c
 }
 
 func (c Coord) Below() Coord {
-	return Coord{c[XAxis], c[YAxis] - 1, c[ZAxis]}
+	c[YAxis]--
+	return c
 }
 
 func (c Coord) Before() Coord {
-	return Coord{c[XAxis], c[YAxis], c[ZAxis] + 1}
+	c[ZAxis]++
+	return c
 }
 
 func (c Coord) Behind() Coord {
-	return Coord{c[XAxis], c[YAxis], c[ZAxis] - 1}
+	c[ZAxis]--
+	return c
 }
 
-// 90 degrees rotation around the specified axis
+// 90 degrees rotation around the specified axis, in the three dimensional space of the X, Y and Z axis
 func (c *Coord) Rotate(axis Axis) (*Coord, error) {
+	result := *c
 	if axis == XAxis {
-		return &Coord{c[XAxis], c[ZAxis], -c[YAxis]}, nil
+		result[YAxis], result[ZAxis] = c[ZAxis], -c[YAxis]
+		return &result, nil
 	}
 	if axis == YAxis {
-		return &Coord{-c[ZAxis], c[YAxis], c[XAxis]}, nil
+		result[XAxis], result[ZAxis] = -c[ZAxis], c[XAxis]
+		return &result, nil
 	}
 	if axis == ZAxis {
-		return &Coord{c[YAxis], -c[XAxis], c[ZAxis]}, nil
+		result[XAxis], result[YAxis] = c[YAxis], -c[XAxis]
+		return &result, nil
 	}
 
 	return nil, fmt.Errorf("unknown axis %d", axis)
@@ -57,17 +69,13 @@ func (c *Coord) MustRotate(axis Axis) *Coord {
 
 // mirror using the plane orthogonal to the specified axis
 func (c *Coord) Mirror(axis Axis) (*Coord, error) {
-	if axis == XAxis {
-		return &Coord{-c[XAxis], c[YAxis], c[ZAxis]}, nil
-	}
-	if axis == YAxis {
-		return &Coord{c[XAxis], -c[YAxis], c[ZAxis]}, nil
-	}
-	if axis == ZAxis {
-		return &Coord{c[XAxis], c[YAxis], -c[ZAxis]}, nil
+	if axis < 0 || axis >= MaxDimensions {
+		return nil, fmt.Errorf("unknown axis %d", axis)
 	}
 
-	return nil, fmt.Errorf("unknown axis %d", axis)
+	result := *c
+	result[axis] = -result[axis]
+	return &result, nil
 }
 
 func (c *Coord) MustMirror(axis Axis) *Coord {
@@ -79,52 +87,65 @@ func (c *Coord) MustMirror(axis Axis) *Coord {
 }
 
 func (c *Coord) Neighbors() map[Coord]struct{} {
-	result := make(map[Coord]struct{}, 6)
-	result[c.Left()] = struct{}{}
-	result[c.Right()] = struct{}{}
-	result[c.Above()] = struct{}{}
-	result[c.Below()] = struct{}{}
-	result[c.Before()] = struct{}{}
-	result[c.Behind()] = struct{}{}
+	return c.NeighborsWithAdjacency(Face, 3)
+}
+
+// NeighborsWithAdjacency returns the neighbors of c using the adjacency in the specified number of dimensions
+func (c *Coord) NeighborsWithAdjacency(adjacency Adjacency, dimensions int) map[Coord]struct{} {
+	deltas := adjacency.NeighborDeltas(dimensions)
+	result := make(map[Coord]struct{}, len(deltas))
+	for _, delta := range deltas {
+		neighbor := *c
+		for axis := range neighbor {
+			neighbor[axis] += delta[axis]
+		}
+		result[neighbor] = struct{}{}
+	}
 
 	return result
 }
 
+// the X, Y and Z coordinates are always written, the W coordinate only when it isn't 0
 func (c *Coord) String() string {
+	if c[WAxis] != 0 {
+		return fmt.Sprintf("[%d %d %d %d]", c[XAxis], c[YAxis], c[ZAxis], c[WAxis])
+	}
 	return fmt.Sprintf("[%d %d %d]", c[XAxis], c[YAxis], c[ZAxis])
 }
 
 func (c *Coord) Equals(other *Coord) bool {
-	return c[XAxis] == other[XAxis] && c[YAxis] == other[YAxis] && c[ZAxis] == other[ZAxis]
+	return *c == *other
 }
 
 func (c *Coord) Subtract(other *Coord) *Coord {
-	return &Coord{
-		c[XAxis] - other[XAxis],
-		c[YAxis] - other[YAxis],
-		c[ZAxis] - other[ZAxis],
+	var result Coord
+	for axis := range c {
+		result[axis] = c[axis] - other[axis]
 	}
+
+	return &result
 }
 
+// CoordFromString parses a coordinate written by String, an error is returned if the coordinate is malformed
 func CoordFromString(s string) (*Coord, error) {
-	var ok bool
-	var err error
-	if s, ok = strings.CutPrefix(s, "["); !ok {
-		return nil, fmt.Errorf("coordinate should start with [")
+	inner, ok := strings.CutPrefix(s, "[")
+	if !ok {
+		return nil, fmt.Errorf("coordinate %q should start with [", s)
 	}
-	if s, ok = strings.CutSuffix(s, "]"); !ok {
-		return nil, fmt.Errorf("coordinate should end with ]")
+	if inner, ok = strings.CutSuffix(inner, "]"); !ok {
+		return nil, fmt.Errorf("coordinate %q should end with ]", s)
 	}
-	fields := strings.Fields(s)
-	result := &Coord{}
-	if result[XAxis], err = strconv.Atoi(fields[XAxis]); err != nil {
-		return nil, err
-	}
-	if result[YAxis], err = strconv.Atoi(fields[YAxis]); err != nil {
-		return nil, err
+	fields := strings.Fields(inner)
+	if len(fields) < 3 || len(fields) > MaxDimensions {
+		return nil, fmt.Errorf("coordinate %q should have 3 to %d values but has %d", s, MaxDimensions, len(fields))
 	}
-	if result[ZAxis], err = strconv.Atoi(fields[ZAxis]); err != nil {
-		return nil, err
+	result := &Coord{}
+	for axis, field := range fields {
+		value, err := strconv.Atoi(field)
+		if err != nil {
+			return nil, fmt.Errorf("coordinate %q has an invalid value %q", s, field)
+		}
+		result[axis] = value
 	}
 	return result, nil
 }
@@ -0,0 +1,202 @@
+package shape
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+)
+
+// the number of rotations and reflections of a hypercube with MaxDimensions dimensions, all counters are multiplied by
+// this number so a shape that is unique under one equivalence can add a fraction of a shape that is unique under an other
+// equivalence. The number of rotations and reflections with less dimensions divides this number.
+const countScale = 384
+
+// Counter keeps track of the number of free, one-sided and fixed shapes per size without keeping the shapes itself. Every
+// shape that is added should be unique under the equivalence of the counter, which is the case for the shapes created by
+// KeepGrowing and KeepGrowingCanonical with the same equivalence.
+type Counter struct {
+	equivalence Equivalence
+	free        map[ShapeSize]int
+	oneSided    map[ShapeSize]int
+	fixed       map[ShapeSize]int
+	pointGroups map[ShapeSize]map[string]int
+	maxSize     ShapeSize
+}
+
+func NewCounter(equivalence Equivalence) *Counter {
+	return &Counter{
+		equivalence: equivalence,
+		free:        make(map[ShapeSize]int),
+		oneSided:    make(map[ShapeSize]int),
+		fixed:       make(map[ShapeSize]int),
+		pointGroups: make(map[ShapeSize]map[string]int),
+	}
+}
+
+func (c *Counter) Add(shape *Shape) *Counter {
+	size := shape.Size()
+	symmetry := shape.Symmetry()
+	rotated := symmetry.NumberOfOrientations(OneSided)
+	rotatedAndMirrored := symmetry.NumberOfOrientations(Free)
+
+	switch c.equivalence {
+	case OneSided:
+		c.free[size] += countScale * rotated / rotatedAndMirrored
+		c.oneSided[size] += countScale
+		c.fixed[size] += countScale * rotated
+	case Free:
+		c.free[size] += countScale
+		c.oneSided[size] += countScale * rotatedAndMirrored / rotated
+		c.fixed[size] += countScale * rotatedAndMirrored
+	case Fixed:
+		c.free[size] += countScale / rotatedAndMirrored
+		c.oneSided[size] += countScale / rotated
+		c.fixed[size] += countScale
+	}
+	if _, ok := c.pointGroups[size]; !ok {
+		c.pointGroups[size] = make(map[string]int)
+	}
+	c.pointGroups[size][symmetry.PointGroup]++
+	if size > c.maxSize {
+		c.maxSize = size
+	}
+
+	return c
+}
+
+func (c *Counter) Merge(other *Counter) *Counter {
+	for size, count := range other.free {
+		c.free[size] += count
+	}
+	for size, count := range other.oneSided {
+		c.oneSided[size] += count
+	}
+	for size, count := range other.fixed {
+		c.fixed[size] += count
+	}
+	for size, pointGroups := range other.pointGroups {
+		if _, ok := c.pointGroups[size]; !ok {
+			c.pointGroups[size] = make(map[string]int)
+		}
+		for pointGroup, count := range pointGroups {
+			c.pointGroups[size][pointGroup] += count
+		}
+	}
+	if other.maxSize > c.maxSize {
+		c.maxSize = other.maxSize
+	}
+
+	return c
+}
+
+// Free is the number of shapes with the specified size that are unique under rotation and reflection, OEIS A038119
+func (c *Counter) Free(size ShapeSize) int {
+	return c.free[size] / countScale
+}
+
+// OneSided is the number of shapes with the specified size that are unique under rotation, OEIS A000162
+func (c *Counter) OneSided(size ShapeSize) int {
+	return c.oneSided[size] / countScale
+}
+
+// Fixed is the number of shapes with the specified size that are unique under translation, OEIS A001931
+func (c *Counter) Fixed(size ShapeSize) int {
+	return c.fixed[size] / countScale
+}
+
+// Exact returns the number of free, one-sided and fixed shapes with the specified size as fractions. When the counter
+// only contains part of the shapes, for example the shapes found by one shard, a shape that is unique under the
+// equivalence of the counter can be part of a shape that is unique under an other equivalence.
+func (c *Counter) Exact(size ShapeSize) (free, oneSided, fixed *big.Rat) {
+	return big.NewRat(int64(c.free[size]), countScale),
+		big.NewRat(int64(c.oneSided[size]), countScale),
+		big.NewRat(int64(c.fixed[size]), countScale)
+}
+
+// Count is the number of shapes with the specified size that are unique under the equivalence of the counter
+func (c *Counter) Count(size ShapeSize) int {
+	switch c.equivalence {
+	case Free:
+		return c.Free(size)
+	case Fixed:
+		return c.Fixed(size)
+	}
+
+	return c.OneSided(size)
+}
+
+// PointGroupCounts is the number of shapes with the specified size, unique under the equivalence of the counter, per
+// point group of the shape
+func (c *Counter) PointGroupCounts(size ShapeSize) map[string]int {
+	result := make(map[string]int, len(c.pointGroups[size]))
+	for pointGroup, count := range c.pointGroups[size] {
+		result[pointGroup] = count
+	}
+
+	return result
+}
+
+func (c *Counter) Equivalence() Equivalence {
+	return c.equivalence
+}
+
+func (c *Counter) MaxSize() ShapeSize {
+	return c.maxSize
+}
+
+type counterJSON struct {
+	Equivalence string                       `json:"equivalence"`
+	Scale       int                          `json:"scale"`
+	Free        map[ShapeSize]int            `json:"free"`
+	OneSided    map[ShapeSize]int            `json:"oneSided"`
+	Fixed       map[ShapeSize]int            `json:"fixed"`
+	PointGroups map[ShapeSize]map[string]int `json:"pointGroups"`
+	MaxSize     ShapeSize                    `json:"maxSize"`
+}
+
+func (c *Counter) MarshalJSON() ([]byte, error) {
+	return json.Marshal(counterJSON{
+		Equivalence: c.equivalence.String(),
+		Scale:       countScale,
+		Free:        c.free,
+		OneSided:    c.oneSided,
+		Fixed:       c.fixed,
+		PointGroups: c.pointGroups,
+		MaxSize:     c.maxSize,
+	})
+}
+
+func (c *Counter) UnmarshalJSON(data []byte) error {
+	var result counterJSON
+	if err := json.Unmarshal(data, &result); err != nil {
+		return err
+	}
+	equivalence, err := EquivalenceFromString(result.Equivalence)
+	if err != nil {
+		return err
+	}
+
+	if result.Scale != countScale {
+		return fmt.Errorf("counter has scale %d but scale %d is expected", result.Scale, countScale)
+	}
+
+	*c = *NewCounter(equivalence)
+	c.maxSize = result.MaxSize
+	for size, count := range result.Free {
+		c.free[size] = count
+	}
+	for size, count := range result.OneSided {
+		c.oneSided[size] = count
+	}
+	for size, count := range result.Fixed {
+		c.fixed[size] = count
+	}
+	for size, pointGroups := range result.PointGroups {
+		c.pointGroups[size] = make(map[string]int, len(pointGroups))
+		for pointGroup, count := range pointGroups {
+			c.pointGroups[size][pointGroup] = count
+		}
+	}
+
+	return nil
+}
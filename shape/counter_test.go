@@ -0,0 +1,68 @@
+package shape_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	. "github.com/munnik/cubes/shape"
+)
+
+func TestCounter(t *testing.T) {
+	expectedFree := []int{1, 1, 2, 7, 23, 112}
+	expectedOneSided := []int{1, 1, 2, 8, 29, 166}
+	expectedFixed := []int{1, 3, 15, 86, 534, 3481}
+
+	for _, equivalence := range []Equivalence{OneSided, Free, Fixed} {
+		counter := NewCounter(equivalence)
+		initialShape := NewShape(NewShapesDefaultMap)
+		counter.Add(initialShape)
+		initialShape.KeepGrowingCanonical(equivalence, ShapeSize(len(expectedFree)), func(s *Shape) {
+			counter.Add(s)
+		})
+
+		for i := range expectedFree {
+			size := ShapeSize(i + 1)
+			if counter.Free(size) != expectedFree[i] {
+				t.Fatalf("Expected %d free shapes with size %d using %v but got %d", expectedFree[i], size, equivalence, counter.Free(size))
+			}
+			if counter.OneSided(size) != expectedOneSided[i] {
+				t.Fatalf("Expected %d one-sided shapes with size %d using %v but got %d", expectedOneSided[i], size, equivalence, counter.OneSided(size))
+			}
+			if counter.Fixed(size) != expectedFixed[i] {
+				t.Fatalf("Expected %d fixed shapes with size %d using %v but got %d", expectedFixed[i], size, equivalence, counter.Fixed(size))
+			}
+		}
+	}
+}
+
+func TestCounterJSON(t *testing.T) {
+	counter := NewCounter(Free)
+	NewShape(nil).KeepGrowingCanonical(Free, 5, func(s *Shape) {
+		counter.Add(s)
+	})
+
+	data, err := json.Marshal(counter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := &Counter{}
+	if err := json.Unmarshal(data, result); err != nil {
+		t.Fatal(err)
+	}
+
+	if result.Equivalence() != Free || result.MaxSize() != 5 {
+		t.Fatalf("Expected a free counter with maximum size 5 but got %v and %d", result.Equivalence(), result.MaxSize())
+	}
+	for size := ShapeSize(1); size <= 5; size++ {
+		if result.Free(size) != counter.Free(size) || result.OneSided(size) != counter.OneSided(size) || result.Fixed(size) != counter.Fixed(size) {
+			t.Fatalf("Expected the same counts for size %d after unmarshalling", size)
+		}
+		if len(result.PointGroupCounts(size)) != len(counter.PointGroupCounts(size)) {
+			t.Fatalf("Expected the same point groups for size %d after unmarshalling", size)
+		}
+	}
+
+	if err := json.Unmarshal([]byte(`{"equivalence":"free","scale":48,"free":{"1":48}}`), result); err == nil {
+		t.Fatalf("Expected an error for a counter with a different scale")
+	}
+}
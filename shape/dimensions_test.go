@@ -0,0 +1,85 @@
+package shape_test
+
+import (
+	"testing"
+
+	. "github.com/munnik/cubes/shape"
+)
+
+func TestDimensionsCounts(t *testing.T) {
+	for _, test := range []struct {
+		dimensions  int
+		equivalence Equivalence
+		expected    []int
+	}{
+		{1, Free, []int{1, 1, 1, 1, 1}},
+		{2, Free, []int{1, 1, 2, 5, 12, 35, 108}},
+		{2, OneSided, []int{1, 1, 2, 7, 18, 60, 196}},
+		{2, Fixed, []int{1, 2, 6, 19, 63, 216, 760}},
+		{4, Free, []int{1, 1, 2, 7, 26}},
+		{4, Fixed, []int{1, 4, 28, 234, 2162}},
+	} {
+		counts := make([]int, len(test.expected))
+		counts[0] = 1
+		NewShapeWithDimensions(NewShapesDefaultMap, test.dimensions).KeepGrowingCanonical(test.equivalence, ShapeSize(len(test.expected)), func(s *Shape) {
+			if s.Dimensions() != test.dimensions {
+				t.Fatalf("Expected a shape with %d dimensions but got %d", test.dimensions, s.Dimensions())
+			}
+			counts[s.Size()-1]++
+		})
+
+		for i := range test.expected {
+			if counts[i] != test.expected[i] {
+				t.Fatalf("Expected %d %v shapes with size %d in %d dimensions but got %d", test.expected[i], test.equivalence, i+1, test.dimensions, counts[i])
+			}
+		}
+	}
+}
+
+func TestDimensionsCounter(t *testing.T) {
+	// 4 free polytesseracts with 4 cells are 234 fixed polytesseracts
+	counter := NewCounter(Free)
+	NewShapeWithDimensions(NewShapesDefaultMap, 4).KeepGrowingCanonical(Free, 4, func(s *Shape) {
+		counter.Add(s)
+	})
+	if counter.Free(4) != 7 || counter.Fixed(4) != 234 {
+		t.Fatalf("Expected 7 free and 234 fixed shapes but got %d and %d", counter.Free(4), counter.Fixed(4))
+	}
+}
+
+func TestDimensionsScore(t *testing.T) {
+	var f func() Shapes
+	s1 := NewShapeWithDimensions(f, 4).MustAddCube(&Coord{0, 0, 0, 1}).MustAddCube(&Coord{0, 0, 0, 2})
+	s2 := NewShapeWithDimensions(f, 4).MustAddCube(&Coord{1, 0, 0, 0}).MustAddCube(&Coord{2, 0, 0, 0})
+	if s1.Score() == s2.Score() {
+		t.Fatalf("Expected two different shapes but got %v and %v", s1.Score(), s2.Score())
+	}
+	if s2.Canonical(Fixed).Score() == s2.Canonical(Free).Score() {
+		t.Fatalf("Expected a fixed canonical shape that differs from the free canonical shape for %v", s2)
+	}
+	if s1.Canonical(Free).Score() != s2.Canonical(Free).Score() {
+		t.Fatalf("Expected %v and %v to be the same free shape", s1, s2)
+	}
+}
+
+func TestCoordFromStringDimensions(t *testing.T) {
+	c, err := CoordFromString("[1 2 3 4]")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *c != (Coord{1, 2, 3, 4}) || c.String() != "[1 2 3 4]" {
+		t.Fatalf("Expected [1 2 3 4] but got %v", c)
+	}
+
+	c, err = CoordFromString("[1 2 3]")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.String() != "[1 2 3]" {
+		t.Fatalf("Expected [1 2 3] but got %v", c)
+	}
+
+	if _, err := CoordFromString("[1 2 3 4 5]"); err == nil {
+		t.Fatalf("Expected an error for a coordinate with 5 values")
+	}
+}
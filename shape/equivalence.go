@@ -0,0 +1,67 @@
+package shape
+
+import "fmt"
+
+// Equivalence defines which shapes are considered to be the same shape
+type Equivalence int
+
+const (
+	OneSided = Equivalence(0) // shapes are the same if one can be rotated and translated into the other, OEIS A000162
+	Free     = Equivalence(1) // shapes are the same if one can be rotated, mirrored and translated into the other, OEIS A038119
+	Fixed    = Equivalence(2) // shapes are the same if one can be translated into the other, OEIS A001931
+)
+
+func (e Equivalence) String() string {
+	switch e {
+	case OneSided:
+		return "one-sided"
+	case Free:
+		return "free"
+	case Fixed:
+		return "fixed"
+	}
+
+	return fmt.Sprintf("unknown equivalence %d", int(e))
+}
+
+func EquivalenceFromString(s string) (Equivalence, error) {
+	for _, e := range []Equivalence{OneSided, Free, Fixed} {
+		if s == e.String() {
+			return e, nil
+		}
+	}
+
+	return 0, fmt.Errorf("unknown equivalence %s", s)
+}
+
+// returns all transformations of the shape that are considered to be the same shape under the equivalence, some of them
+// can be equal if the shape is symmetric
+func (s *Shape) Transformations(equivalence Equivalence) []*Shape {
+	matrices := IsometriesWithDimensions(equivalence, s.Dimensions())
+	result := make([]*Shape, 0, len(matrices))
+	for _, m := range matrices {
+		result = append(result, s.Apply(m))
+	}
+
+	return result
+}
+
+// returns the number of different orientations of the shape under the equivalence
+func (s *Shape) NumberOfOrientations(equivalence Equivalence) int {
+	return s.Symmetry().NumberOfOrientations(equivalence)
+}
+
+// returns the transformation of the shape, translated to positive coordinates, that represents all the shapes that are
+// the same under the equivalence
+func (s *Shape) Canonical(equivalence Equivalence) *Shape {
+	if equivalence == Fixed {
+		return s.AllPositiveCoords()
+	}
+
+	return s.orient(IsometriesWithDimensions(equivalence, s.Dimensions()))
+}
+
+// true if the mirror image of the shape can't be created by rotating the shape
+func (s *Shape) IsChiral() bool {
+	return s.Canonical(OneSided).Cmp(s.MustMirror(XAxis).Canonical(OneSided)) != 0
+}
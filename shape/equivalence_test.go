@@ -0,0 +1,42 @@
+package shape_test
+
+import (
+	"testing"
+
+	. "github.com/munnik/cubes/shape"
+)
+
+func TestCanonical(t *testing.T) {
+	var f func() Shapes
+	s1 := NewShape(f).MustAddCube(&Coord{1, 0, 0}).MustAddCube(&Coord{1, 1, 0}).MustAddCube(&Coord{1, 1, 1})
+	s2 := s1.MustMirror(XAxis)
+
+	if s1.Canonical(OneSided).Cmp(s2.Canonical(OneSided)) == 0 {
+		t.Fatalf("Expected %v and %v to be different one-sided shapes", s1, s2)
+	}
+	if s1.Canonical(Free).Cmp(s2.Canonical(Free)) != 0 {
+		t.Fatalf("Expected %v and %v to be the same free shape", s1, s2)
+	}
+	if !s1.IsChiral() {
+		t.Fatalf("Expected %v to be chiral", s1)
+	}
+
+	s3 := s1.MustRotate(ZAxis)
+	if s1.Canonical(Fixed).Cmp(s3.Canonical(Fixed)) == 0 {
+		t.Fatalf("Expected %v and %v to be different fixed shapes", s1, s3)
+	}
+	if s1.Canonical(OneSided).Cmp(s3.Canonical(OneSided)) != 0 {
+		t.Fatalf("Expected %v and %v to be the same one-sided shape", s1, s3)
+	}
+}
+
+func TestEquivalenceFromString(t *testing.T) {
+	for _, e := range []Equivalence{OneSided, Free, Fixed} {
+		if result, err := EquivalenceFromString(e.String()); err != nil || result != e {
+			t.Fatalf("Expected %v but got %v and error %v", e, result, err)
+		}
+	}
+	if _, err := EquivalenceFromString("unknown"); err == nil {
+		t.Fatalf("Expected an error for an unknown equivalence")
+	}
+}
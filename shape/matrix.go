@@ -0,0 +1,147 @@
+package shape
+
+// Matrix is an integer matrix that transforms a coordinate, all the rotations and reflections of a hypercube are signed
+// permutation matrices. The transformations of shapes with less than MaxDimensions dimensions don't change the other
+// axes.
+type Matrix [MaxDimensions][MaxDimensions]int
+
+var identity = newIdentity()
+
+// the rotations and reflections of a hypercube for every number of dimensions, also known as the hyperoctahedral group.
+// The rotations come first starting with the identity. In 3 dimensions these are the 24 rotations and 24 reflections of
+// a cube.
+var isometries = newIsometries()
+
+func newIdentity() Matrix {
+	var result Matrix
+	for i := range result {
+		result[i][i] = 1
+	}
+
+	return result
+}
+
+func newIsometries() [MaxDimensions + 1][]Matrix {
+	var result [MaxDimensions + 1][]Matrix
+	for dimensions := 1; dimensions <= MaxDimensions; dimensions++ {
+		rotations := make([]Matrix, 0)
+		reflections := make([]Matrix, 0)
+		for _, p := range permutations(dimensions) {
+			for signs := 0; signs < 1<<dimensions; signs++ {
+				m := identity
+				for row := range p {
+					m[row][row] = 0
+				}
+				for row, axis := range p {
+					m[row][axis] = 1
+					if signs&(1<<row) != 0 {
+						m[row][axis] = -1
+					}
+				}
+				if m.Determinant() == 1 {
+					rotations = append(rotations, m)
+				} else {
+					reflections = append(reflections, m)
+				}
+			}
+		}
+		result[dimensions] = append(rotations, reflections...)
+	}
+
+	return result
+}
+
+// returns all permutations of the first axes in lexicographic order
+func permutations(dimensions int) [][]Axis {
+	if dimensions == 0 {
+		return [][]Axis{{}}
+	}
+
+	result := make([][]Axis, 0)
+	for first := 0; first < dimensions; first++ {
+		for _, rest := range permutations(dimensions - 1) {
+			p := []Axis{Axis(first)}
+			for _, axis := range rest {
+				if int(axis) >= first {
+					axis++
+				}
+				p = append(p, axis)
+			}
+			result = append(result, p)
+		}
+	}
+
+	return result
+}
+
+// Isometries returns the rotations and reflections of a cube that are allowed under the equivalence, starting with the
+// identity
+func Isometries(equivalence Equivalence) []Matrix {
+	return IsometriesWithDimensions(equivalence, 3)
+}
+
+// IsometriesWithDimensions returns the rotations and reflections of a hypercube with the specified number of dimensions
+// that are allowed under the equivalence, starting with the identity
+func IsometriesWithDimensions(equivalence Equivalence, dimensions int) []Matrix {
+	switch equivalence {
+	case OneSided:
+		// a rotation in 1 dimension can only be the identity, for more dimensions half of the isometries are rotations
+		return isometries[dimensions][:(len(isometries[dimensions])+1)/2]
+	case Free:
+		return isometries[dimensions]
+	}
+
+	return isometries[dimensions][:1]
+}
+
+func (m Matrix) Apply(c Coord) Coord {
+	var result Coord
+	for row := range m {
+		for column, value := range m[row] {
+			result[row] += value * c[column]
+		}
+	}
+
+	return result
+}
+
+// Determinant of the matrix using Laplace expansion
+func (m Matrix) Determinant() int {
+	rows := make([][]int, len(m))
+	for i := range m {
+		rows[i] = m[i][:]
+	}
+
+	return determinant(rows)
+}
+
+func determinant(rows [][]int) int {
+	if len(rows) == 1 {
+		return rows[0][0]
+	}
+
+	result := 0
+	sign := 1
+	for column, value := range rows[0] {
+		if value != 0 {
+			minor := make([][]int, 0, len(rows)-1)
+			for _, row := range rows[1:] {
+				minor = append(minor, append(append(make([]int, 0, len(row)-1), row[:column]...), row[column+1:]...))
+			}
+			result += sign * value * determinant(minor)
+		}
+		sign = -sign
+	}
+
+	return result
+}
+
+// Trace of the matrix, the axes that are not transformed add 1 each
+func (m Matrix) Trace() int {
+	result := 0
+	for i := range m {
+		result += m[i][i]
+	}
+
+	return result
+}
@@ -0,0 +1,129 @@
+package shape
+
+// Orientations calls fn for every rotation and reflection that is allowed under the equivalence with the matrix and the
+// shape transformed by the matrix and translated to positive coordinates, starting with the identity. Some of the
+// oriented shapes can be equal if the shape is symmetric. Iterating stops when fn returns false.
+func (s *Shape) Orientations(equivalence Equivalence, fn func(m Matrix, oriented *Shape) bool) {
+	box := s.BoundingBox()
+	for _, m := range IsometriesWithDimensions(equivalence, s.Dimensions()) {
+		oriented := &Shape{
+			coords:            s.orientedCoords(m, box, make([]packedCoord, 0, len(s.coords))),
+			dimensions:        s.dimensions,
+			adjacency:         s.adjacency,
+			allPositiveCoords: true,
+			newShapes:         s.newShapes,
+		}
+		if !fn(m, oriented) {
+			return
+		}
+	}
+}
+
+// returns the shape with the biggest score of all the orientations created by the matrices, translated to positive
+// coordinates. Only the coordinates of the orientations are compared, no shapes or scores are created. The coordinates
+// of an orientation are only sorted as far as needed to find that it is smaller than the best orientation so far.
+func (s *Shape) orient(matrices []Matrix) *Shape {
+	box := s.BoundingBox()
+	best := s.orientedCoords(matrices[0], box, make([]packedCoord, 0, len(s.coords)))
+	candidate := make([]packedCoord, 0, len(s.coords))
+	for _, m := range matrices[1:] {
+		candidate = s.transformedCoords(m, box, candidate)
+		if result, sorted := compareSelecting(candidate, best); result > 0 {
+			sortPacked(candidate[sorted:])
+			best, candidate = candidate, best
+		}
+	}
+
+	return &Shape{
+		coords:            best,
+		dimensions:        s.dimensions,
+		adjacency:         s.adjacency,
+		allPositiveCoords: true,
+		newShapes:         s.newShapes,
+	}
+}
+
+// returns the matrices that map the shape onto itself
+func (s *Shape) stabilizer(matrices []Matrix) []Matrix {
+	box := s.BoundingBox()
+	original := s.orientedCoords(identity, box, make([]packedCoord, 0, len(s.coords)))
+	candidate := make([]packedCoord, 0, len(s.coords))
+	result := make([]Matrix, 0, len(matrices))
+	for _, m := range matrices {
+		candidate = s.transformedCoords(m, box, candidate)
+		if compared, _ := compareSelecting(candidate, original); compared == 0 {
+			result = append(result, m)
+		}
+	}
+
+	return result
+}
+
+// writes the coords of the shape, transformed by m and translated to positive coordinates, to buffer and returns the
+// sorted coords
+func (s *Shape) orientedCoords(m Matrix, box BoundingBox, buffer []packedCoord) []packedCoord {
+	buffer = s.transformedCoords(m, box, buffer)
+	sortPacked(buffer)
+
+	return buffer
+}
+
+// writes the coords of the shape, transformed by m and translated to positive coordinates, to buffer and returns the
+// coords in the order of the shape. The translation is calculated from the bounding box of the original shape so every
+// coordinate is only transformed once.
+func (s *Shape) transformedCoords(m Matrix, box BoundingBox, buffer []packedCoord) []packedCoord {
+	// m is a signed permutation matrix, every row takes one column with a sign
+	var offset Coord
+	var columns [MaxDimensions]int
+	var signs [MaxDimensions]int
+	for row := range m {
+		for column, value := range m[row] {
+			if value == 1 {
+				offset[row] = box.Min[column]
+			} else if value == -1 {
+				offset[row] = -box.Max[column]
+			}
+			if value != 0 {
+				columns[row], signs[row] = column, value
+			}
+		}
+	}
+
+	buffer = buffer[:0]
+	for _, p := range s.coords {
+		c := p.unpack()
+		var transformed Coord
+		for row := range transformed {
+			transformed[row] = signs[row]*c[columns[row]] - offset[row]
+		}
+		buffer = append(buffer, pack(transformed))
+	}
+
+	return buffer
+}
+
+// compares the unsorted coords of candidate with the sorted coords, both with the same length. The smallest coord of
+// candidate that is left is moved to the front and compared with the coord at the same index of sorted, so candidate is
+// only sorted up to the first difference. Returns -1 if candidate is smaller than sorted, 0 if they are equal and 1 if
+// candidate is bigger, like comparing the sorted coords, and the number of coords at the start of candidate that are
+// sorted.
+func compareSelecting(candidate, sorted []packedCoord) (int, int) {
+	for i := range candidate {
+		smallest := i
+		for j := i + 1; j < len(candidate); j++ {
+			if candidate[j] < candidate[smallest] {
+				smallest = j
+			}
+		}
+		candidate[i], candidate[smallest] = candidate[smallest], candidate[i]
+
+		if candidate[i] < sorted[i] {
+			return -1, i + 1
+		}
+		if candidate[i] > sorted[i] {
+			return 1, i + 1
+		}
+	}
+
+	return 0, len(candidate)
+}
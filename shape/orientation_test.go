@@ -0,0 +1,59 @@
+package shape_test
+
+import (
+	"testing"
+
+	. "github.com/munnik/cubes/shape"
+)
+
+func TestOrientations(t *testing.T) {
+	var f func() Shapes
+	s := NewShape(f).MustAddCube(&Coord{1, 0, 0}).MustAddCube(&Coord{0, 1, 0})
+
+	for equivalence, expected := range map[Equivalence]int{Fixed: 1, OneSided: 12, Free: 12} {
+		count := 0
+		scores := make(map[Score]struct{})
+		s.Orientations(equivalence, func(m Matrix, oriented *Shape) bool {
+			count++
+			scores[oriented.Score()] = struct{}{}
+			if oriented.BoundingBox().Min != (Coord{0, 0, 0}) {
+				t.Fatalf("Expected %v to be translated to positive coordinates", oriented)
+			}
+			if oriented.Cmp(s.Apply(m)) != 0 {
+				t.Fatalf("Expected %v to be the same as %v", oriented, s.Apply(m))
+			}
+			return true
+		})
+		if count != len(Isometries(equivalence)) || len(scores) != expected {
+			t.Fatalf("Expected %d different orientations using %v but got %d", expected, equivalence, len(scores))
+		}
+	}
+
+	count := 0
+	s.Orientations(Free, func(m Matrix, oriented *Shape) bool {
+		count++
+		return count < 5
+	})
+	if count != 5 {
+		t.Fatalf("Expected iterating to stop after 5 orientations but got %d", count)
+	}
+}
+
+func TestCanonicalIsBiggestOrientation(t *testing.T) {
+	grown := NewPool(1).KeepGrowing([]*Shape{NewShape(NewShapesDefaultMap)}, 6)
+
+	for _, s := range grown.GetAll() {
+		for _, equivalence := range []Equivalence{Fixed, OneSided, Free} {
+			var biggest *Shape
+			s.Orientations(equivalence, func(m Matrix, oriented *Shape) bool {
+				if biggest == nil || oriented.Cmp(biggest) > 0 {
+					biggest = oriented
+				}
+				return true
+			})
+			if canonical := s.Canonical(equivalence); canonical.Cmp(biggest) != 0 {
+				t.Fatalf("Expected the canonical shape of %v using %v to be %v but got %v", s, equivalence, biggest, canonical)
+			}
+		}
+	}
+}
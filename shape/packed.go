@@ -0,0 +1,95 @@
+package shape
+
+import "fmt"
+
+const (
+	packedBits   = 16
+	packedOffset = 1 << (packedBits - 1)
+	packedMask   = 1<<packedBits - 1
+)
+
+// packedCoord stores a coordinate in a single integer, every axis uses packedBits bits. The order of packed coordinates
+// is the order of the W coordinates, then the Z coordinates, then the Y coordinates and then the X coordinates, which is
+// the order used by Score.
+type packedCoord uint64
+
+// the difference between the packed coordinates of two neighbors for every adjacency and number of dimensions, a
+// neighbor is found by adding the difference. Subtracting wraps around but every axis is stored with an offset so the
+// result is correct. The face neighbors come first.
+var neighborOffsets = newNeighborOffsets()
+
+func newNeighborOffsets() [Vertex + 1][MaxDimensions + 1][]packedCoord {
+	var result [Vertex + 1][MaxDimensions + 1][]packedCoord
+	for adjacency := range result {
+		for dimensions := range result[adjacency] {
+			maxAxes := Adjacency(adjacency).axes(dimensions)
+			for axes := 1; axes <= maxAxes; axes++ {
+				result[adjacency][dimensions] = appendOffsets(result[adjacency][dimensions], dimensions, axes, 0, 0)
+			}
+		}
+	}
+
+	return result
+}
+
+// appends the differences that change exactly axes of the first dimensions axes by 1 or -1, starting at axis with the
+// partial difference offset
+func appendOffsets(result []packedCoord, dimensions int, axes int, axis int, offset packedCoord) []packedCoord {
+	if axes == 0 {
+		return append(result, offset)
+	}
+	for ; axis <= dimensions-axes; axis++ {
+		unit := packedCoord(1) << (packedBits * axis)
+		result = appendOffsets(result, dimensions, axes-1, axis+1, offset+unit)
+		result = appendOffsets(result, dimensions, axes-1, axis+1, offset-unit)
+	}
+
+	return result
+}
+
+func pack(c Coord) packedCoord {
+	var result packedCoord
+	for _, axis := range []Axis{WAxis, ZAxis, YAxis, XAxis} {
+		if c[axis] < -packedOffset || c[axis] >= packedOffset {
+			panic(fmt.Sprintf("coord %v can't be packed", c))
+		}
+		result = result<<packedBits | packedCoord(c[axis]+packedOffset)
+	}
+
+	return result
+}
+
+func (p packedCoord) unpack() Coord {
+	var result Coord
+	for _, axis := range []Axis{XAxis, YAxis, ZAxis, WAxis} {
+		result[axis] = int(p&packedMask) - packedOffset
+		p >>= packedBits
+	}
+
+	return result
+}
+
+// sorts the coords in place, shapes are small so an insertion sort is fast and doesn't allocate
+func sortPacked(coords []packedCoord) {
+	for i := 1; i < len(coords); i++ {
+		for j := i; j > 0 && coords[j] < coords[j-1]; j-- {
+			coords[j], coords[j-1] = coords[j-1], coords[j]
+		}
+	}
+}
+
+// returns the index of p in the sorted coords and true if coords contains p, otherwise the index where p should be
+// inserted and false
+func searchPacked(coords []packedCoord, p packedCoord) (int, bool) {
+	low, high := 0, len(coords)
+	for low < high {
+		middle := int(uint(low+high) >> 1)
+		if coords[middle] < p {
+			low = middle + 1
+		} else {
+			high = middle
+		}
+	}
+
+	return low, low < len(coords) && coords[low] == p
+}
@@ -0,0 +1,310 @@
+package shape
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// Pool grows shapes using a fixed number of workers. Every worker has its own queue of parent shapes, a worker takes the
+// parent that was added last to its own queue so the shapes are grown depth first and the queues stay small. A worker
+// with an empty queue steals the parent that was added first to the queue of an other worker, which is usually the parent
+// with the biggest subtree.
+type Pool struct {
+	workers  int
+	observer Observer
+	visited  *Visited
+	box      *Box
+}
+
+// Observer is notified about the progress of a Pool, Found and Done are called concurrently by the workers
+type Observer interface {
+	// Start is called when the pool starts growing the parents
+	Start(parents []*Shape)
+	// Found is called for every shape that is found by KeepGrowing and KeepGrowingCanonical, KeepGrowing can find the
+	// same shape more than once
+	Found(s *Shape)
+	// Done is called when one of the parents and all the shapes grown from it are done
+	Done(parent *Shape)
+}
+
+func NewPool(workers int) *Pool {
+	if workers < 1 {
+		workers = 1
+	}
+
+	return &Pool{workers: workers}
+}
+
+func (p *Pool) Workers() int {
+	return p.workers
+}
+
+// SetObserver sets the observer that is notified about the progress, nil removes the observer
+func (p *Pool) SetObserver(observer Observer) {
+	p.observer = observer
+}
+
+// Observer returns the observer that is notified about the progress, nil if there is no observer
+func (p *Pool) Observer() Observer {
+	return p.observer
+}
+
+// SetVisited sets the shapes that are already expanded by KeepGrowing, shapes in visited are not expanded again so the
+// same subtree is only grown once. Nil expands every shape that is found.
+func (p *Pool) SetVisited(visited *Visited) {
+	p.visited = visited
+}
+
+// SetBox restricts the shapes that are grown by the pool to shapes that fit in the box, nil removes the restriction
+func (p *Pool) SetBox(box *Box) {
+	p.box = box
+}
+
+// Box returns the box the shapes that are grown by the pool should fit in, nil if there is no restriction
+func (p *Pool) Box() *Box {
+	return p.box
+}
+
+// a shape that has to be grown and the index of the parent it is grown from
+type task struct {
+	shape  *Shape
+	parent int
+}
+
+type queue struct {
+	mu    sync.Mutex
+	tasks []task
+}
+
+func (q *queue) push(tasks []task) {
+	q.mu.Lock()
+	q.tasks = append(q.tasks, tasks...)
+	q.mu.Unlock()
+}
+
+// removes the task that was added last
+func (q *queue) pop() (task, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.tasks) == 0 {
+		return task{}, false
+	}
+	result := q.tasks[len(q.tasks)-1]
+	q.tasks[len(q.tasks)-1] = task{}
+	q.tasks = q.tasks[:len(q.tasks)-1]
+
+	return result, true
+}
+
+// removes the task that was added first
+func (q *queue) steal() (task, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.tasks) == 0 {
+		return task{}, false
+	}
+	result := q.tasks[0]
+	q.tasks[0] = task{}
+	q.tasks = q.tasks[1:]
+
+	return result, true
+}
+
+// Run calls work for every parent and for every shape returned by work until there is no work left. work is called
+// concurrently by the workers, worker is the index of the worker that calls work and is smaller than Workers, so work can
+// keep state per worker without locking.
+func (p *Pool) Run(parents []*Shape, work func(worker int, parent *Shape) []*Shape) {
+	queues := make([]*queue, p.workers)
+	for i := range queues {
+		queues[i] = &queue{}
+	}
+	// pendingPerParent is the number of shapes grown from a parent that are queued or being worked on
+	pendingPerParent := make([]int64, len(parents))
+	for i, parent := range parents {
+		pendingPerParent[i] = 1
+		queues[i%p.workers].push([]task{{shape: parent, parent: i}})
+	}
+	if p.observer != nil {
+		p.observer.Start(parents)
+	}
+
+	// pending is the number of shapes that are queued or being worked on
+	pending := int64(len(parents))
+	idle := int32(0)
+	mu := sync.Mutex{}
+	cond := sync.NewCond(&mu)
+	wakeUp := func() {
+		if atomic.LoadInt32(&idle) > 0 {
+			mu.Lock()
+			cond.Broadcast()
+			mu.Unlock()
+		}
+	}
+	next := func(worker int) (task, bool) {
+		if t, ok := queues[worker].pop(); ok {
+			return t, true
+		}
+		for i := 1; i < p.workers; i++ {
+			if t, ok := queues[(worker+i)%p.workers].steal(); ok {
+				return t, true
+			}
+		}
+
+		return task{}, false
+	}
+
+	wg := sync.WaitGroup{}
+	wg.Add(p.workers)
+	for worker := 0; worker < p.workers; worker++ {
+		go func(worker int) {
+			defer wg.Done()
+			for {
+				t, ok := next(worker)
+				if !ok {
+					mu.Lock()
+					// idle is increased before looking for work so a worker that adds work after this always wakes
+					// this worker up
+					atomic.AddInt32(&idle, 1)
+					for !ok && atomic.LoadInt64(&pending) > 0 {
+						if t, ok = next(worker); !ok {
+							cond.Wait()
+						}
+					}
+					atomic.AddInt32(&idle, -1)
+					mu.Unlock()
+					if !ok {
+						return
+					}
+				}
+
+				children := work(worker, t.shape)
+				if len(children) > 0 {
+					tasks := make([]task, len(children))
+					for i, child := range children {
+						tasks[i] = task{shape: child, parent: t.parent}
+					}
+					atomic.AddInt64(&pendingPerParent[t.parent], int64(len(children)))
+					atomic.AddInt64(&pending, int64(len(children)))
+					queues[worker].push(tasks)
+					wakeUp()
+				}
+				if atomic.AddInt64(&pendingPerParent[t.parent], -1) == 0 && p.observer != nil {
+					p.observer.Done(parents[t.parent])
+				}
+				if atomic.AddInt64(&pending, -1) == 0 {
+					wakeUp()
+				}
+			}
+		}(worker)
+	}
+	wg.Wait()
+}
+
+// KeepGrowing returns all unique shapes starting from the parents until the shapes reach the specified maxSize, the
+// shapes are collected like GrowLevel does. When the pool has visited shapes, shapes that are already expanded are
+// skipped. Shapes with maxSize are never expanded, so they are not visited.
+func (p *Pool) KeepGrowing(parents []*Shape, maxSize ShapeSize) Shapes {
+	return p.collect(parents, func(parent *Shape, equivalence Equivalence, add func(s *Shape)) []*Shape {
+		if parent.Size() > maxSize {
+			return nil
+		}
+		canonical := parent.Canonical(equivalence)
+		if parent.Size() < maxSize && p.visited != nil && !p.visited.Visit(canonical.Score()) {
+			return nil
+		}
+		add(canonical)
+		if parent.Size() == maxSize {
+			return nil
+		}
+
+		return p.box.filter(parent.grow(equivalence))
+	})
+}
+
+// GrowLevel returns all unique shapes that are grown from the level by adding one cube, every shape in the level is
+// grown once. The shapes are collected in Shapes created by the newShapes method of the first shape in the level. When
+// that is ShapesConcurrent all workers add to it, otherwise every worker collects the shapes it finds in its own Shapes
+// and these are merged when all shapes are grown.
+func (p *Pool) GrowLevel(level []*Shape) Shapes {
+	return p.collect(level, func(parent *Shape, equivalence Equivalence, add func(s *Shape)) []*Shape {
+		for _, child := range p.box.filter(parent.grow(equivalence)) {
+			add(child)
+		}
+
+		return nil
+	})
+}
+
+// collect runs work for the parents and returns the shapes work adds, work returns the shapes that have to be worked on
+func (p *Pool) collect(parents []*Shape, work func(parent *Shape, equivalence Equivalence, add func(s *Shape)) []*Shape) Shapes {
+	if len(parents) == 0 {
+		return nil
+	}
+
+	results := make([]Shapes, p.workers)
+	results[0] = parents[0].newShapes()
+	_, concurrent := results[0].(*ShapesConcurrent)
+	for i := 1; i < len(results); i++ {
+		if concurrent {
+			results[i] = results[0]
+		} else {
+			results[i] = parents[0].newShapes()
+		}
+	}
+	equivalence := results[0].Equivalence()
+
+	// every worker collects in its own ShapesDisk, the budget is divided so the workers together keep the budget in
+	// memory
+	var budget int64
+	disk, isDisk := results[0].(*ShapesDisk)
+	if isDisk {
+		budget = disk.budget
+		for _, result := range results {
+			result.(*ShapesDisk).budget = budget / int64(len(results))
+		}
+	}
+
+	p.Run(parents, func(worker int, parent *Shape) []*Shape {
+		return work(parent, equivalence, func(s *Shape) {
+			results[worker].Add(*s)
+			if p.observer != nil {
+				p.observer.Found(s)
+			}
+		})
+	})
+
+	if !concurrent {
+		for _, result := range results[1:] {
+			results[0].Merge(result)
+			CloseShapes(result)
+		}
+	}
+	if isDisk {
+		disk.budget = budget
+	}
+
+	return results[0]
+}
+
+// KeepGrowingCanonical calls emit for every unique shape under the equivalence that can be grown from the parents until
+// the shapes reach the specified maxSize, the parents are not emitted. The shapes are grown with the box of the pool
+// like Shape.KeepGrowingCanonicalInBox does, emit is called concurrently by the workers with the index of the worker.
+func (p *Pool) KeepGrowingCanonical(parents []*Shape, equivalence Equivalence, maxSize ShapeSize, emit func(worker int, s *Shape)) {
+	p.Run(parents, func(worker int, parent *Shape) []*Shape {
+		if parent.Size() >= maxSize {
+			return nil
+		}
+
+		children := p.box.filter(parent.CanonicalChildren(equivalence))
+		for _, child := range children {
+			if p.observer != nil {
+				p.observer.Found(child)
+			}
+			emit(worker, child)
+		}
+		if parent.Size()+1 >= maxSize {
+			return nil
+		}
+		return children
+	})
+}
@@ -0,0 +1,128 @@
+package shape_test
+
+import (
+	"sync"
+	"testing"
+
+	. "github.com/munnik/cubes/shape"
+)
+
+func TestPoolKeepGrowing(t *testing.T) {
+	expected := []int{1, 1, 2, 8, 29, 166}
+
+	for _, newShapes := range []func() Shapes{NewShapesDefaultMap, NewShapesConcurrent, NewShapesDiskWithBudget(4096)} {
+		for _, workers := range []int{1, 4} {
+			shapes := NewPool(workers).KeepGrowing([]*Shape{NewShape(newShapes)}, ShapeSize(len(expected)))
+			for i := range expected {
+				if len(shapes.GetAllWithSize(ShapeSize(i+1))) != expected[i] {
+					t.Fatalf("Expected %d shapes with size %d using %d workers but got %d", expected[i], i+1, workers, len(shapes.GetAllWithSize(ShapeSize(i+1))))
+				}
+			}
+			if disk, ok := shapes.(*ShapesDisk); ok {
+				if err := disk.Close(); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+	}
+}
+
+func TestPoolKeepGrowingCanonical(t *testing.T) {
+	expected := []int{0, 1, 2, 8, 29, 166, 1023}
+
+	for _, workers := range []int{1, 3, 8} {
+		mu := sync.Mutex{}
+		counts := make([]int, len(expected))
+		pool := NewPool(workers)
+		pool.KeepGrowingCanonical([]*Shape{NewShape(nil)}, OneSided, ShapeSize(len(expected)), func(worker int, s *Shape) {
+			if worker < 0 || worker >= pool.Workers() {
+				t.Errorf("Unexpected worker %d", worker)
+			}
+			mu.Lock()
+			counts[s.Size()-1]++
+			mu.Unlock()
+		})
+		for i := range expected {
+			if counts[i] != expected[i] {
+				t.Fatalf("Expected %d shapes with size %d using %d workers but got %d", expected[i], i+1, workers, counts[i])
+			}
+		}
+	}
+}
+
+type testObserver struct {
+	mu      sync.Mutex
+	parents int
+	found   map[ShapeSize]int
+	done    map[Score]int
+}
+
+func (o *testObserver) Start(parents []*Shape) {
+	o.mu.Lock()
+	o.parents = len(parents)
+	o.mu.Unlock()
+}
+
+func (o *testObserver) Found(s *Shape) {
+	o.mu.Lock()
+	o.found[s.Size()]++
+	o.mu.Unlock()
+}
+
+func (o *testObserver) Done(parent *Shape) {
+	o.mu.Lock()
+	o.done[parent.Score()]++
+	o.mu.Unlock()
+}
+
+func TestPoolObserver(t *testing.T) {
+	expected := []int{0, 0, 0, 8, 29, 166}
+	parents := make([]*Shape, 0)
+	NewShape(nil).KeepGrowingCanonical(OneSided, 3, func(s *Shape) {
+		if s.Size() == 3 {
+			parents = append(parents, s)
+		}
+	})
+
+	for _, workers := range []int{1, 4} {
+		observer := &testObserver{found: make(map[ShapeSize]int), done: make(map[Score]int)}
+		pool := NewPool(workers)
+		pool.SetObserver(observer)
+		pool.KeepGrowingCanonical(parents, OneSided, ShapeSize(len(expected)), func(worker int, s *Shape) {})
+
+		if observer.parents != len(parents) {
+			t.Fatalf("Expected %d parents using %d workers but got %d", len(parents), workers, observer.parents)
+		}
+		for i := range expected {
+			if observer.found[ShapeSize(i+1)] != expected[i] {
+				t.Fatalf("Expected %d shapes with size %d using %d workers but got %d", expected[i], i+1, workers, observer.found[ShapeSize(i+1)])
+			}
+		}
+		for _, parent := range parents {
+			if observer.done[parent.Score()] != 1 {
+				t.Fatalf("Expected parent %v to be done once using %d workers but got %d", parent, workers, observer.done[parent.Score()])
+			}
+		}
+	}
+}
+
+func TestPoolGrowLevel(t *testing.T) {
+	expected := []int{1, 1, 2, 8, 29, 166}
+
+	for _, newShapes := range []func() Shapes{NewShapesDefaultMap, NewShapesConcurrent} {
+		for _, workers := range []int{1, 4} {
+			pool := NewPool(workers)
+			level := []*Shape{NewShape(newShapes)}
+			for i := 1; i < len(expected); i++ {
+				shapes := pool.GrowLevel(level)
+				if shapes.Len() != expected[i] || shapes.MaxSize() != ShapeSize(i+1) {
+					t.Fatalf("Expected %d shapes with size %d using %d workers but got %d shapes up to size %d", expected[i], i+1, workers, shapes.Len(), shapes.MaxSize())
+				}
+				level = level[:0]
+				for _, shape := range shapes.GetAll() {
+					level = append(level, shape)
+				}
+			}
+		}
+	}
+}
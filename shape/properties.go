@@ -0,0 +1,183 @@
+package shape
+
+import (
+	"math"
+	"sort"
+)
+
+// Properties are geometric properties of a shape. The properties that depend on the orientation of the shape are
+// calculated in the canonical orientation under the Free equivalence, so all orientations of a shape have the same
+// properties. Slices have a value for every dimension of the shape, in the order X, Y, Z and W.
+type Properties struct {
+	Size             ShapeSize
+	SurfaceArea      int       // number of faces of the cubes that are not shared with another cube
+	Contacts         int       // number of faces shared by two cubes
+	BoundingBox      []int     // number of cubes along every axis of the bounding box
+	CenterOfMass     []float64 // every cube has mass 1 and its center at the coordinate plus 0.5
+	PrincipalMoments []float64 // the principal moments of inertia around the center of mass, smallest first
+	Compactness      float64   // surface area of a cube with the same size divided by the surface area, 1 for a cube
+	Diameter         int       // the longest shortest path between two cubes, moving between neighbors
+	LongestStraight  int
+}
+
+// Properties returns the geometric properties of the shape
+func (s *Shape) Properties() *Properties {
+	dimensions := s.Dimensions()
+	canonical := s.Canonical(Free)
+	coords := canonical.Coords()
+	box := canonical.BoundingBox()
+
+	result := &Properties{
+		Size:             s.Size(),
+		Contacts:         s.contacts(),
+		BoundingBox:      make([]int, dimensions),
+		CenterOfMass:     make([]float64, dimensions),
+		Diameter:         s.diameter(),
+		LongestStraight:  s.LongestStraight(),
+		PrincipalMoments: principalMoments(coords, dimensions),
+	}
+	result.SurfaceArea = 2*dimensions*int(s.Size()) - 2*result.Contacts
+	// a cube with edges of length k has size k^d and surface area 2d * k^(d-1)
+	result.Compactness = 2 * float64(dimensions) * math.Pow(float64(s.Size()), float64(dimensions-1)/float64(dimensions)) / float64(result.SurfaceArea)
+	for axis := 0; axis < dimensions; axis++ {
+		result.BoundingBox[axis] = box.Max[axis] - box.Min[axis] + 1
+		for _, c := range coords {
+			result.CenterOfMass[axis] += float64(c[axis]) + 0.5
+		}
+		result.CenterOfMass[axis] /= float64(len(coords))
+	}
+
+	return result
+}
+
+// returns the number of pairs of cubes that share a face
+func (s *Shape) contacts() int {
+	result := 0
+	for _, p := range s.coords {
+		for _, offset := range neighborOffsets[Face][s.Dimensions()] {
+			if _, ok := searchPacked(s.coords, p+offset); ok {
+				result++
+			}
+		}
+	}
+
+	// every contact is found from both cubes
+	return result / 2
+}
+
+// returns the longest shortest path between two cubes, moving between neighbors using the adjacency of the shape
+func (s *Shape) diameter() int {
+	offsets := neighborOffsets[s.adjacency][s.Dimensions()]
+	result := 0
+	distances := make([]int, len(s.coords))
+	queue := make([]int, 0, len(s.coords))
+	for start := range s.coords {
+		for i := range distances {
+			distances[i] = -1
+		}
+		distances[start] = 0
+		queue = append(queue[:0], start)
+		for len(queue) > 0 {
+			current := queue[0]
+			queue = queue[1:]
+			if distances[current] > result {
+				result = distances[current]
+			}
+			for _, offset := range offsets {
+				index, ok := searchPacked(s.coords, s.coords[current]+offset)
+				if !ok || distances[index] >= 0 {
+					continue
+				}
+				distances[index] = distances[current] + 1
+				queue = append(queue, index)
+			}
+		}
+	}
+
+	return result
+}
+
+// returns the eigenvalues of the inertia tensor of the cubes around their center of mass, smallest first. Every cube
+// adds its own inertia of (d-1)/12 around every axis through its center.
+func principalMoments(coords []Coord, dimensions int) []float64 {
+	center := make([]float64, dimensions)
+	for _, c := range coords {
+		for axis := 0; axis < dimensions; axis++ {
+			center[axis] += float64(c[axis])
+		}
+	}
+	for axis := range center {
+		center[axis] /= float64(len(coords))
+	}
+
+	tensor := make([][]float64, dimensions)
+	for i := range tensor {
+		tensor[i] = make([]float64, dimensions)
+	}
+	for _, c := range coords {
+		r := make([]float64, dimensions)
+		squared := 0.0
+		for axis := range r {
+			r[axis] = float64(c[axis]) - center[axis]
+			squared += r[axis] * r[axis]
+		}
+		for i := range tensor {
+			tensor[i][i] += squared + float64(dimensions-1)/12
+			for j := range tensor[i] {
+				tensor[i][j] -= r[i] * r[j]
+			}
+		}
+	}
+
+	return symmetricEigenvalues(tensor)
+}
+
+// returns the eigenvalues of the symmetric matrix m using the Jacobi eigenvalue algorithm, smallest first, m is changed
+func symmetricEigenvalues(m [][]float64) []float64 {
+	n := len(m)
+	for sweep := 0; sweep < 50; sweep++ {
+		offDiagonal := 0.0
+		for i := 0; i < n; i++ {
+			for j := i + 1; j < n; j++ {
+				offDiagonal += m[i][j] * m[i][j]
+			}
+		}
+		if offDiagonal < 1e-18 {
+			break
+		}
+
+		for p := 0; p < n; p++ {
+			for q := p + 1; q < n; q++ {
+				if math.Abs(m[p][q]) < 1e-12 {
+					continue
+				}
+				// rotate rows and columns p and q so m[p][q] becomes 0
+				theta := (m[q][q] - m[p][p]) / (2 * m[p][q])
+				t := 1 / (math.Abs(theta) + math.Sqrt(theta*theta+1))
+				if theta < 0 {
+					t = -t
+				}
+				c := 1 / math.Sqrt(t*t+1)
+				s := t * c
+				for k := 0; k < n; k++ {
+					mkp, mkq := m[k][p], m[k][q]
+					m[k][p] = c*mkp - s*mkq
+					m[k][q] = s*mkp + c*mkq
+				}
+				for k := 0; k < n; k++ {
+					mpk, mqk := m[p][k], m[q][k]
+					m[p][k] = c*mpk - s*mqk
+					m[q][k] = s*mpk + c*mqk
+				}
+			}
+		}
+	}
+
+	result := make([]float64, n)
+	for i := range result {
+		result[i] = m[i][i]
+	}
+	sort.Float64s(result)
+
+	return result
+}
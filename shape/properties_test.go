@@ -0,0 +1,63 @@
+package shape_test
+
+import (
+	"math"
+	"testing"
+
+	. "github.com/munnik/cubes/shape"
+)
+
+func TestProperties(t *testing.T) {
+	var f func() Shapes
+	domino := NewShape(f).MustAddCube(&Coord{0, 1, 0})
+	p := domino.Properties()
+	if p.Size != 2 || p.SurfaceArea != 10 || p.Contacts != 1 || p.Diameter != 1 || p.LongestStraight != 2 {
+		t.Fatalf("Expected size 2, surface area 10, 1 contact, diameter 1 and longest straight 2 but got %+v", p)
+	}
+	if p.BoundingBox[0]*p.BoundingBox[1]*p.BoundingBox[2] != 2 {
+		t.Fatalf("Expected a bounding box of 2 cubes but got %v", p.BoundingBox)
+	}
+	expectedMoments := []float64{1.0 / 3, 5.0 / 6, 5.0 / 6}
+	for i := range expectedMoments {
+		if math.Abs(p.PrincipalMoments[i]-expectedMoments[i]) > 1e-9 {
+			t.Fatalf("Expected principal moments %v but got %v", expectedMoments, p.PrincipalMoments)
+		}
+	}
+
+	cube := NewShape(f)
+	for _, c := range []Coord{{1, 0, 0}, {0, 1, 0}, {1, 1, 0}, {0, 0, 1}, {1, 0, 1}, {0, 1, 1}, {1, 1, 1}} {
+		cube = cube.MustAddCube(&c)
+	}
+	p = cube.Properties()
+	if p.SurfaceArea != 24 || p.Contacts != 12 || p.Diameter != 3 || math.Abs(p.Compactness-1) > 1e-9 {
+		t.Fatalf("Expected surface area 24, 12 contacts, diameter 3 and compactness 1 but got %+v", p)
+	}
+	for axis, center := range p.CenterOfMass {
+		if center != 1 {
+			t.Fatalf("Expected the center of mass at 1 for axis %d but got %v", axis, p.CenterOfMass)
+		}
+	}
+	for _, moment := range p.PrincipalMoments {
+		if math.Abs(moment-8*2*4/12.0) > 1e-9 {
+			t.Fatalf("Expected principal moments of %v but got %v", 8*2*4/12.0, p.PrincipalMoments)
+		}
+	}
+	cube.SetAdjacency(Vertex)
+	if p := cube.Properties(); p.Diameter != 1 {
+		t.Fatalf("Expected diameter 1 with vertex adjacency but got %d", p.Diameter)
+	}
+}
+
+func TestPropertiesOrientation(t *testing.T) {
+	var f func() Shapes
+	s := NewShape(f).MustAddCube(&Coord{1, 0, 0}).MustAddCube(&Coord{2, 0, 0}).MustAddCube(&Coord{2, 1, 0}).MustAddCube(&Coord{2, 1, 1})
+	expected := s.Properties()
+	for _, oriented := range s.Transformations(Free) {
+		p := oriented.Properties()
+		for axis := range expected.BoundingBox {
+			if p.BoundingBox[axis] != expected.BoundingBox[axis] || math.Abs(p.CenterOfMass[axis]-expected.CenterOfMass[axis]) > 1e-9 || math.Abs(p.PrincipalMoments[axis]-expected.PrincipalMoments[axis]) > 1e-9 {
+				t.Fatalf("Expected the same properties %+v for %v but got %+v", expected, oriented, p)
+			}
+		}
+	}
+}
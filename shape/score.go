@@ -1,52 +1,96 @@
 package shape
 
 import (
-	"sort"
+	"fmt"
+	"hash/fnv"
+	"strings"
 )
 
-const (
-	MAX_NUMBER_OF_CUBES = ShapeSize(17)
-)
-
-type Score [MAX_NUMBER_OF_CUBES]uint64
+// Score identifies a shape independent of its position, two shapes have the same score if one can be translated into
+// the other. The score contains the coordinates of all cubes after translating the shape to positive coordinates,
+// ordered by the W, Z, Y and X coordinate. Only the axes the shape grows in are stored, so a score of a 3D shape has the
+// Z, Y and X coordinates. Every coordinate uses as many bytes as needed to store the size of the shape.
+type Score string
 
 func NewScore(s *Shape) *Score {
-	result := &Score{}
-	size := int(s.Size())
-	sizeSquared := size * size
-
-	index := 0
-	for c := range s.AllPositiveCoords().coords {
-		result[index] = uint64(c[XAxis]) + uint64(c[YAxis]*size) + uint64(c[ZAxis]*sizeSquared) + 1
-		index++
+	width := scoreWidth(s.Size())
+	axes := scoreAxes(s.Dimensions())
+	result := make([]byte, 0, len(s.coords)*width*len(axes))
+	for _, p := range s.AllPositiveCoords().coords {
+		c := p.unpack()
+		for _, axis := range axes {
+			for i := width - 1; i >= 0; i-- {
+				result = append(result, byte(c[axis]>>(8*i)))
+			}
+		}
 	}
 
-	sort.Sort(result)
-
-	return result
+	score := Score(result)
+	return &score
 }
 
-func (s Score) Len() int {
-	return len(s)
+// returns the axes that are stored in the score of a shape with the specified number of dimensions, in the order they
+// are stored
+func scoreAxes(dimensions int) []Axis {
+	return []Axis{WAxis, ZAxis, YAxis, XAxis}[MaxDimensions-dimensions:]
 }
 
-func (s Score) Less(i, j int) bool {
-	return s[i] < s[j]
+// returns the number of bytes needed to store a coordinate of a shape with the specified size
+func scoreWidth(size ShapeSize) int {
+	result := 1
+	for max := int(size) - 1; max > 0xff; max >>= 8 {
+		result++
+	}
+
+	return result
 }
 
-func (s *Score) Swap(i, j int) {
-	s[i], s[j] = s[j], s[i]
+// ScoreLength returns the number of bytes of the score of a shape with the size and the number of dimensions, the scores
+// of all shapes with the same size and number of dimensions have the same length
+func ScoreLength(size ShapeSize, dimensions int) int {
+	return len(scoreAxes(dimensions)) * scoreWidth(size) * int(size)
 }
 
 // Compare s to other, return -1 if left is smaller than right, 0 if left is equal to right and 1 if left is bigger than right
 func (left Score) Cmp(right Score) int {
-	for index := ShapeSize(0); index < MAX_NUMBER_OF_CUBES; index++ {
-		if left[index] < right[index] {
-			return -1
+	return strings.Compare(string(left), string(right))
+}
+
+// Shard returns the shard, from 0 up to shards, the score belongs to. A score always belongs to the same shard, also
+// when the shard is calculated by an other process or on an other machine.
+func (s Score) Shard(shards int) int {
+	h := fnv.New64a()
+	h.Write([]byte(s))
+
+	return int(h.Sum64() % uint64(shards))
+}
+
+// ShapeFromScore returns the shape with the specified number of dimensions the score is created from, the shape has only
+// positive coordinates
+func ShapeFromScore(score Score, dimensions int) (*Shape, error) {
+	axes := scoreAxes(dimensions)
+	for width := 1; len(axes)*width <= len(score); width++ {
+		if len(score)%(len(axes)*width) != 0 {
+			continue
+		}
+		size := ShapeSize(len(score) / (len(axes) * width))
+		if scoreWidth(size) != width {
+			continue
 		}
-		if left[index] > right[index] {
-			return 1
+
+		coords := make([]packedCoord, 0, size)
+		for i := 0; i < len(score); i += len(axes) * width {
+			var c Coord
+			for j, axis := range axes {
+				for k := 0; k < width; k++ {
+					c[axis] = c[axis]<<8 | int(score[i+j*width+k])
+				}
+			}
+			coords = append(coords, pack(c))
 		}
+		result := &Shape{coords: coords, dimensions: dimensions, allPositiveCoords: true, score: &score}
+		return result, nil
 	}
-	return 0
+
+	return nil, fmt.Errorf("score with length %d is invalid", len(score))
 }
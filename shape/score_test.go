@@ -15,3 +15,65 @@ func TestScore(t *testing.T) {
 		t.Fatalf("Expected two different shapes but got %v and %v", s1.Score(), s2.Score())
 	}
 }
+
+func TestScoreLargeShape(t *testing.T) {
+	var f func() Shapes
+	s1 := NewShape(f)
+	for i := 1; i < 300; i++ {
+		s1 = s1.MustAddCube(&Coord{i, 0, 0})
+	}
+	s2 := s1.MustAddCube(&Coord{0, 1, 0})
+	s3 := s1.MustAddCube(&Coord{0, -1, 0})
+
+	if s2.Score() == s3.Score() || s2.CanonicalRotation().Score() != s3.CanonicalRotation().Score() {
+		t.Fatalf("Expected two different shapes that are the same after rotation")
+	}
+
+	shapes := NewShapesLongestStraightMap().Add(*s1).Add(*s2).Add(*s3.CanonicalRotation()).Add(*s2.CanonicalRotation())
+	if shapes.Len() != 3 || shapes.MaxSize() != 301 {
+		t.Fatalf("Expected 3 shapes with a maximum size of 301 but got %d and %d", shapes.Len(), shapes.MaxSize())
+	}
+}
+
+func TestScoreShard(t *testing.T) {
+	shards := 4
+	counts := make([]int, shards)
+	NewShape(nil).KeepGrowingCanonical(OneSided, 6, func(s *Shape) {
+		shard := s.Score().Shard(shards)
+		if shard < 0 || shard >= shards {
+			t.Fatalf("Expected a shard from 0 up to %d but got %d", shards, shard)
+		}
+		if shard != s.MustRotate(XAxis).CanonicalRotation().Score().Shard(shards) {
+			t.Fatalf("Expected the same shard for the same shape %v", s)
+		}
+		counts[shard]++
+	})
+
+	for shard, count := range counts {
+		if count == 0 {
+			t.Fatalf("Expected shapes in shard %d", shard)
+		}
+	}
+}
+
+func TestShapeFromScore(t *testing.T) {
+	for dimensions := 1; dimensions <= MaxDimensions; dimensions++ {
+		NewShapeWithDimensions(nil, dimensions).KeepGrowingCanonical(Fixed, 5, func(s *Shape) {
+			score := s.Score()
+			if len(score) != ScoreLength(s.Size(), dimensions) {
+				t.Fatalf("Expected a score with length %d but got %d for %v", ScoreLength(s.Size(), dimensions), len(score), s)
+			}
+			result, err := ShapeFromScore(score, dimensions)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Dimensions() != dimensions || result.Score() != score || result.String() != s.AllPositiveCoords().String() {
+				t.Fatalf("Expected %v but got %v", s.AllPositiveCoords(), result)
+			}
+		})
+	}
+
+	if _, err := ShapeFromScore(Score("\x00\x00"), 3); err == nil {
+		t.Fatalf("Expected an error for a score with an invalid length")
+	}
+}
@@ -4,13 +4,14 @@ import (
 	"fmt"
 	"sort"
 	"strings"
-	"sync"
 )
 
 const SEPARATOR = ", "
 
 type Shape struct {
-	coords            map[Coord]struct{}
+	coords            []packedCoord // sorted, see packedCoord
+	dimensions        int           // 0 is the same as 3 dimensions
+	adjacency         Adjacency
 	allPositiveCoords bool
 	score             *Score
 	newShapes         func() Shapes
@@ -24,9 +25,31 @@ type BoundingBox struct {
 }
 
 func NewShape(newShapes func() Shapes) *Shape {
+	return NewShapeWithDimensions(newShapes, 3)
+}
+
+// NewShapeWithDimensions returns a shape with a single cube that grows in the specified number of dimensions, from 1 up
+// to and including MaxDimensions
+func NewShapeWithDimensions(newShapes func() Shapes, dimensions int) *Shape {
+	if dimensions < 1 || dimensions > MaxDimensions {
+		panic(fmt.Sprintf("a shape should have 1 to %d dimensions", MaxDimensions))
+	}
+
 	return &Shape{
-		coords:    map[Coord]struct{}{{0, 0, 0}: {}},
-		newShapes: newShapes,
+		coords:     []packedCoord{pack(Coord{})},
+		dimensions: dimensions,
+		newShapes:  newShapes,
+	}
+}
+
+// returns a new shape like s that uses coords, coords should not contain duplicates and are sorted in place
+func (s *Shape) withCoords(coords []packedCoord) *Shape {
+	sortPacked(coords)
+	return &Shape{
+		coords:     coords,
+		dimensions: s.dimensions,
+		adjacency:  s.adjacency,
+		newShapes:  s.newShapes,
 	}
 }
 
@@ -34,11 +57,48 @@ func (s *Shape) SetNewShapesMethod(newShapes func() Shapes) {
 	s.newShapes = newShapes
 }
 
+// Dimensions returns the number of dimensions the shape grows in, only the first axes of the coordinates are used
+func (s *Shape) Dimensions() int {
+	if s.dimensions == 0 {
+		return 3
+	}
+
+	return s.dimensions
+}
+
+// SetDimensions sets the number of dimensions the shape grows in, the coordinates of the shape should only use the first
+// axes
+func (s *Shape) SetDimensions(dimensions int) {
+	if dimensions < 1 || dimensions > MaxDimensions {
+		panic(fmt.Sprintf("a shape should have 1 to %d dimensions", MaxDimensions))
+	}
+	s.dimensions = dimensions
+	s.score = nil
+}
+
+// Adjacency returns which cubes are neighbors, the shape grows by adding neighbors
+func (s *Shape) Adjacency() Adjacency {
+	return s.adjacency
+}
+
+// SetAdjacency sets which cubes are neighbors, the shape should be connected using the adjacency
+func (s *Shape) SetAdjacency(adjacency Adjacency) {
+	s.adjacency = adjacency
+}
+
 // Size is the number of cubes in the collection.
 func (s *Shape) Size() ShapeSize { return (ShapeSize)(len(s.coords)) }
 
+// true if the shape contains a cube at c
+func (s *Shape) Contains(c Coord) bool {
+	_, ok := searchPacked(s.coords, pack(c))
+	return ok
+}
+
 func (s *Shape) AddCube(newCoord *Coord) (*Shape, error) {
-	if _, ok := s.coords[*newCoord]; ok {
+	packed := pack(*newCoord)
+	index, ok := searchPacked(s.coords, packed)
+	if ok {
 		return nil, fmt.Errorf("coord %v is already in this shape", newCoord)
 	}
 
@@ -46,14 +106,12 @@ func (s *Shape) AddCube(newCoord *Coord) (*Shape, error) {
 		return nil, fmt.Errorf("coord %v is not a neighbor of this shape", newCoord)
 	}
 
-	result := NewShape(s.newShapes)
-	result.coords = make(map[Coord]struct{}, s.Size()+1)
-	for c := range s.coords {
-		result.coords[c] = struct{}{}
-	}
-	result.coords[*newCoord] = struct{}{}
+	coords := make([]packedCoord, 0, len(s.coords)+1)
+	coords = append(coords, s.coords[:index]...)
+	coords = append(coords, packed)
+	coords = append(coords, s.coords[index:]...)
 
-	return result, nil
+	return &Shape{coords: coords, dimensions: s.dimensions, adjacency: s.adjacency, newShapes: s.newShapes}, nil
 }
 
 func (s *Shape) MustAddCube(c *Coord) *Shape {
@@ -65,49 +123,116 @@ func (s *Shape) MustAddCube(c *Coord) *Shape {
 	return result
 }
 
-// returns all possible new shapes with one cube added to the original shape
-func (s *Shape) Grow() Shapes {
-	newCoords := make(map[Coord]struct{})
-	for c := range s.coords {
-		newCoords[c.Left()] = struct{}{}
-		newCoords[c.Right()] = struct{}{}
-		newCoords[c.Above()] = struct{}{}
-		newCoords[c.Below()] = struct{}{}
-		newCoords[c.Before()] = struct{}{}
-		newCoords[c.Behind()] = struct{}{}
+func (s *Shape) RemoveCube(oldCoord *Coord) (*Shape, error) {
+	index, ok := searchPacked(s.coords, pack(*oldCoord))
+	if !ok {
+		return nil, fmt.Errorf("coord %v is not in this shape", oldCoord)
 	}
-	for c := range s.coords {
-		delete(newCoords, c)
+
+	coords := make([]packedCoord, 0, len(s.coords)-1)
+	coords = append(coords, s.coords[:index]...)
+	coords = append(coords, s.coords[index+1:]...)
+
+	return &Shape{coords: coords, dimensions: s.dimensions, adjacency: s.adjacency, newShapes: s.newShapes}, nil
+}
+
+func (s *Shape) MustRemoveCube(c *Coord) *Shape {
+	result, err := s.RemoveCube(c)
+	if err != nil {
+		panic(err)
 	}
 
+	return result
+}
+
+// true if every cube in the shape can be reached from every other cube by moving between neighbors
+func (s *Shape) IsConnected() bool {
+	if s.Size() == 0 {
+		return true
+	}
+
+	visited := make([]bool, len(s.coords))
+	queue := make([]int, 1, len(s.coords))
+	visited[0] = true
+	for len(queue) > 0 {
+		p := s.coords[queue[0]]
+		queue = queue[1:]
+		for _, offset := range neighborOffsets[s.adjacency][s.Dimensions()] {
+			index, ok := searchPacked(s.coords, p+offset)
+			if !ok || visited[index] {
+				continue
+			}
+			visited[index] = true
+			queue = append(queue, index)
+		}
+	}
+
+	for _, v := range visited {
+		if !v {
+			return false
+		}
+	}
+
+	return true
+}
+
+// returns all coords that are not part of the shape but are a neighbor of the shape, every coord is returned once
+func (s *Shape) candidateCoords() []Coord {
+	offsets := neighborOffsets[s.adjacency][s.Dimensions()]
+	packed := make([]packedCoord, 0, len(offsets)*len(s.coords))
+	for _, p := range s.coords {
+		for _, offset := range offsets {
+			if _, ok := searchPacked(s.coords, p+offset); !ok {
+				packed = append(packed, p+offset)
+			}
+		}
+	}
+	sortPacked(packed)
+
+	result := make([]Coord, 0, len(packed))
+	for i, p := range packed {
+		if i == 0 || p != packed[i-1] {
+			result = append(result, p.unpack())
+		}
+	}
+
+	return result
+}
+
+// returns all possible new shapes with one cube added to the original shape
+func (s *Shape) Grow() Shapes {
 	result := s.newShapes()
-	numberOfNewShapes := len(newCoords)
-	channel := make(chan *Shape, numberOfNewShapes)
-	wg := sync.WaitGroup{}
-	wg.Add(numberOfNewShapes)
-	for c := range newCoords {
-		go func(c Coord) {
-			newShape := s.MustAddCube(&c)
-			channel <- newShape.WithSmallestScore()
-			wg.Done()
-		}(c)
-	}
-	wg.Wait()
-	close(channel)
-
-	for shape := range channel {
+	for _, shape := range s.grow(result.Equivalence()) {
 		result.Add(*shape)
 	}
 
 	return result
 }
 
+// returns all unique shapes under the equivalence with one cube added to the original shape, without creating Shapes
+func (s *Shape) grow(equivalence Equivalence) []*Shape {
+	newCoords := s.candidateCoords()
+
+	seen := make(map[Score]struct{}, len(newCoords))
+	result := make([]*Shape, 0, len(newCoords))
+	for _, c := range newCoords {
+		shape := s.MustAddCube(&c).Canonical(equivalence)
+		if _, ok := seen[shape.Score()]; ok {
+			continue
+		}
+		seen[shape.Score()] = struct{}{}
+		result = append(result, shape)
+	}
+
+	return result
+}
+
+// true if c is a neighbor of a cube of the shape using the adjacency of the shape
 func (s *Shape) IsNeighbor(c *Coord) bool {
-	for existing := range s.coords {
-		for neighbor := range existing.Neighbors() {
-			if c.Equals(&neighbor) {
-				return true
-			}
+	p := pack(*c)
+	for _, offset := range neighborOffsets[s.adjacency][s.Dimensions()] {
+		if _, ok := searchPacked(s.coords, p+offset); ok {
+			return true
 		}
 	}
 
@@ -116,8 +241,8 @@ func (s *Shape) IsNeighbor(c *Coord) bool {
 
 func (s *Shape) Coords() []Coord {
 	result := make([]Coord, 0, s.Size())
-	for c := range s.coords {
-		result = append(result, c)
+	for _, p := range s.coords {
+		result = append(result, p.unpack())
 	}
 
 	return result
@@ -127,40 +252,18 @@ func (s *Shape) LongestStraight() int {
 	result := 0
 
 	for _, c := range s.Coords() {
-		straight := 0
-		isNextIn := true
-		next := c
-		for isNextIn {
-			straight++
-			next = next.Right()
-			_, isNextIn = s.coords[next]
-		}
-		if straight > result {
-			result = straight
-		}
-
-		straight = 0
-		isNextIn = true
-		next = c
-		for isNextIn {
-			straight++
-			next = next.Above()
-			_, isNextIn = s.coords[next]
-		}
-		if straight > result {
-			result = straight
-		}
-
-		straight = 0
-		isNextIn = true
-		next = c
-		for isNextIn {
-			straight++
-			next = next.Before()
-			_, isNextIn = s.coords[next]
-		}
-		if straight > result {
-			result = straight
+		for axis := 0; axis < s.Dimensions(); axis++ {
+			straight := 0
+			isNextIn := true
+			next := c
+			for isNextIn {
+				straight++
+				next[axis]--
+				isNextIn = s.Contains(next)
+			}
+			if straight > result {
+				result = straight
+			}
 		}
 	}
 
@@ -168,18 +271,16 @@ func (s *Shape) LongestStraight() int {
 }
 
 func (s *Shape) Transform(f func(Coord, Axis) (*Coord, error), axis Axis) (*Shape, error) {
-	result := NewShape(s.newShapes)
-	result.coords = make(map[Coord]struct{}, s.Size())
-
-	for c := range s.coords {
-		newCoord, err := f(c, axis)
+	coords := make([]packedCoord, 0, len(s.coords))
+	for _, p := range s.coords {
+		newCoord, err := f(p.unpack(), axis)
 		if err != nil {
 			return nil, err
 		}
-		result.coords[*newCoord] = struct{}{}
+		coords = append(coords, pack(*newCoord))
 	}
 
-	return result, nil
+	return s.withCoords(coords), nil
 }
 
 func (s *Shape) MustTransform(f func(Coord, Axis) (*Coord, error), axis Axis) *Shape {
@@ -230,8 +331,11 @@ func (s *Shape) MustMirror(axis Axis) *Shape {
 func (s *Shape) BoundingBox() BoundingBox {
 	var min, max Coord
 
-	for _, c := range s.Coords() {
-		for _, axis := range []Axis{XAxis, YAxis, ZAxis} {
+	for i, c := range s.Coords() {
+		if i == 0 {
+			min, max = c, c
+		}
+		for axis := range c {
 			if c[axis] < min[axis] {
 				min[axis] = c[axis]
 			}
@@ -252,15 +356,14 @@ func (s *Shape) AllPositiveCoords() *Shape {
 		return s
 	}
 
-	min := s.BoundingBox().Min
-
-	result := NewShape(s.newShapes)
-	result.coords = make(map[Coord]struct{}, s.Size())
-
-	for c := range s.coords {
-		result.coords[*c.Subtract(&min)] = struct{}{}
+	// subtracting the same value from every coordinate doesn't change the order of the packed coordinates
+	offset := pack(s.BoundingBox().Min) - pack(Coord{0, 0, 0})
+	coords := make([]packedCoord, 0, len(s.coords))
+	for _, p := range s.coords {
+		coords = append(coords, p-offset)
 	}
 
+	result := &Shape{coords: coords, dimensions: s.dimensions, adjacency: s.adjacency, newShapes: s.newShapes}
 	result.allPositiveCoords = true
 	return result
 }
@@ -286,128 +389,16 @@ func (left *Shape) Cmp(right *Shape) int {
 	return left.Score().Cmp(right.Score())
 }
 
-// returns the shape with the smallest score by rotating the original shape
-func (s *Shape) WithSmallestScore() *Shape {
-	// https://stackoverflow.com/questions/16452383/how-to-get-all-24-rotations-of-a-3-dimensional-array
-	// RTTTRTTTRTTT
-	// RTR
-	// RTTTRTTTRTTT
-
-	result := s
-	turnedShape := s
-
-	// RTTT
-	turnedShape = turnedShape.MustRotate(XAxis)
-	if result.Cmp(turnedShape) < 0 {
-		result = turnedShape
-	}
-	turnedShape = turnedShape.MustRotate(YAxis)
-	if result.Cmp(turnedShape) < 0 {
-		result = turnedShape
-	}
-	turnedShape = turnedShape.MustRotate(YAxis)
-	if result.Cmp(turnedShape) < 0 {
-		result = turnedShape
-	}
-	turnedShape = turnedShape.MustRotate(YAxis)
-	if result.Cmp(turnedShape) < 0 {
-		result = turnedShape
-	}
-
-	// RTTT
-	turnedShape = turnedShape.MustRotate(XAxis)
-	if result.Cmp(turnedShape) < 0 {
-		result = turnedShape
-	}
-	turnedShape = turnedShape.MustRotate(YAxis)
-	if result.Cmp(turnedShape) < 0 {
-		result = turnedShape
-	}
-	turnedShape = turnedShape.MustRotate(YAxis)
-	if result.Cmp(turnedShape) < 0 {
-		result = turnedShape
-	}
-	turnedShape = turnedShape.MustRotate(YAxis)
-	if result.Cmp(turnedShape) < 0 {
-		result = turnedShape
-	}
-
-	// RTTT
-	turnedShape = turnedShape.MustRotate(XAxis)
-	if result.Cmp(turnedShape) < 0 {
-		result = turnedShape
-	}
-	turnedShape = turnedShape.MustRotate(YAxis)
-	if result.Cmp(turnedShape) < 0 {
-		result = turnedShape
-	}
-	turnedShape = turnedShape.MustRotate(YAxis)
-	if result.Cmp(turnedShape) < 0 {
-		result = turnedShape
-	}
-	turnedShape = turnedShape.MustRotate(YAxis)
-	if result.Cmp(turnedShape) < 0 {
-		result = turnedShape
-	}
-
-	// RTR
-	turnedShape = turnedShape.MustRotate(XAxis).MustRotate(YAxis).MustRotate(XAxis)
-
-	// RTTT
-	turnedShape = turnedShape.MustRotate(XAxis)
-	if result.Cmp(turnedShape) < 0 {
-		result = turnedShape
-	}
-	turnedShape = turnedShape.MustRotate(YAxis)
-	if result.Cmp(turnedShape) < 0 {
-		result = turnedShape
-	}
-	turnedShape = turnedShape.MustRotate(YAxis)
-	if result.Cmp(turnedShape) < 0 {
-		result = turnedShape
-	}
-	turnedShape = turnedShape.MustRotate(YAxis)
-	if result.Cmp(turnedShape) < 0 {
-		result = turnedShape
-	}
-
-	// RTTT
-	turnedShape = turnedShape.MustRotate(XAxis)
-	if result.Cmp(turnedShape) < 0 {
-		result = turnedShape
-	}
-	turnedShape = turnedShape.MustRotate(YAxis)
-	if result.Cmp(turnedShape) < 0 {
-		result = turnedShape
-	}
-	turnedShape = turnedShape.MustRotate(YAxis)
-	if result.Cmp(turnedShape) < 0 {
-		result = turnedShape
-	}
-	turnedShape = turnedShape.MustRotate(YAxis)
-	if result.Cmp(turnedShape) < 0 {
-		result = turnedShape
-	}
-
-	// RTTT
-	turnedShape = turnedShape.MustRotate(XAxis)
-	if result.Cmp(turnedShape) < 0 {
-		result = turnedShape
-	}
-	turnedShape = turnedShape.MustRotate(YAxis)
-	if result.Cmp(turnedShape) < 0 {
-		result = turnedShape
-	}
-	turnedShape = turnedShape.MustRotate(YAxis)
-	if result.Cmp(turnedShape) < 0 {
-		result = turnedShape
-	}
-	turnedShape = turnedShape.MustRotate(YAxis)
-	if result.Cmp(turnedShape) < 0 {
-		result = turnedShape
-	}
+// returns all rotations of the shape in its dimensions, like the 24 rotations in 3 dimensions, some of them can be equal
+// if the shape is symmetric
+func (s *Shape) Rotations() []*Shape {
+	return s.Transformations(OneSided)
+}
 
-	return result.AllPositiveCoords()
+// returns the rotation of the shape that represents all its rotations, the rotation with the biggest score translated to
+// positive coordinates
+func (s *Shape) CanonicalRotation() *Shape {
+	return s.Canonical(OneSided)
 }
 
 // KeepGrowing returns all unique shapes starting from the initial Shape until the shapes reach the specified maxLen
@@ -416,50 +407,91 @@ func (initialShape *Shape) KeepGrowing(maxSize ShapeSize, returnChannel chan Sha
 		return
 	}
 
-	smallestScoreShape := initialShape.WithSmallestScore()
-	result := initialShape.newShapes()
-	result.Add(*smallestScoreShape)
+	returnChannel <- initialShape.keepGrowing(maxSize)
+}
 
-	grown := initialShape.Grow().GetAllWithSize(initialShape.Size() + 1)
+func (initialShape *Shape) keepGrowing(maxSize ShapeSize) Shapes {
+	result := initialShape.newShapes()
+	result.Add(*initialShape.Canonical(result.Equivalence()))
+	if initialShape.Size() == maxSize {
+		return result
+	}
 
-	requestChannel := make(chan Shapes, len(grown))
-	wg := sync.WaitGroup{}
-	wg.Add(len(grown))
-	for _, shape := range grown {
-		func(s *Shape) {
-			defer wg.Done()
-			s.KeepGrowing(maxSize, requestChannel)
-		}(shape)
+	grown := initialShape.Grow()
+	for _, shape := range grown.GetAllWithSize(initialShape.Size() + 1) {
+		grownShapes := shape.keepGrowing(maxSize)
+		result.Merge(grownShapes)
+		CloseShapes(grownShapes)
 	}
-	wg.Wait()
-	close(requestChannel)
+	CloseShapes(grown)
+
+	return result
+}
 
-	for m := range requestChannel {
-		result.Merge(m)
+// Validate returns an error if the shape uses axes beyond its dimensions or isn't connected using its adjacency
+func (s *Shape) Validate() error {
+	for _, c := range s.Coords() {
+		for axis := s.Dimensions(); axis < MaxDimensions; axis++ {
+			if c[axis] != 0 {
+				return fmt.Errorf("coordinate %v uses more than %d dimensions", &c, s.Dimensions())
+			}
+		}
+	}
+	if !s.IsConnected() {
+		return fmt.Errorf("shape is not connected using %v adjacency", s.adjacency)
 	}
 
-	returnChannel <- result
+	return nil
 }
 
 func (s *Shape) String() string {
-	coords := make([]string, 0)
-	for c := range s.coords {
+	coords := make([]string, 0, len(s.coords))
+	for _, c := range s.Coords() {
 		coords = append(coords, c.String())
 	}
 	sort.Strings(coords)
 	return strings.Join(coords, SEPARATOR)
 }
 
+// ShapeFromString parses a shape written by String, an error is returned if the shape is malformed
 func ShapeFromString(s string) (*Shape, error) {
-	result := &Shape{coords: make(map[Coord]struct{})}
 	coordStrings := strings.Split(s, SEPARATOR)
+	coords := make([]Coord, 0, len(coordStrings))
 	for _, coordString := range coordStrings {
 		coord, err := CoordFromString(coordString)
 		if err != nil {
 			return nil, err
 		}
-		result.coords[*coord] = struct{}{}
+		coords = append(coords, *coord)
+	}
+
+	return ShapeFromCoords(coords)
+}
+
+// ShapeFromCoords returns the shape with a cube at every coordinate, an error is returned if there are no coordinates or
+// a coordinate is out of range
+func ShapeFromCoords(coords []Coord) (*Shape, error) {
+	if len(coords) == 0 {
+		return nil, fmt.Errorf("a shape should have at least one cube")
+	}
+	packed := make([]packedCoord, 0, len(coords))
+	for _, coord := range coords {
+		for _, value := range coord {
+			if value < -packedOffset || value >= packedOffset {
+				return nil, fmt.Errorf("coordinate %v is out of range", &coord)
+			}
+		}
+		packed = append(packed, pack(coord))
+	}
+	sortPacked(packed)
+
+	// remove duplicate coordinates
+	result := packed[:0]
+	for i, p := range packed {
+		if i == 0 || p != packed[i-1] {
+			result = append(result, p)
+		}
 	}
 
-	return result, nil
+	return &Shape{coords: result}, nil
 }
@@ -8,14 +8,14 @@ import (
 
 func TestCmp(t *testing.T) {
 	var f func() Shapes
-	s1 := NewShape(f).MustAddCube(&Coord{1, 0, 0}).MustAddCube(&Coord{2, 0, 0}).WithSmallestScore()
-	s2 := NewShape(f).MustAddCube(&Coord{1, 0, 0}).MustAddCube(&Coord{0, 1, 0}).WithSmallestScore()
+	s1 := NewShape(f).MustAddCube(&Coord{1, 0, 0}).MustAddCube(&Coord{2, 0, 0}).CanonicalRotation()
+	s2 := NewShape(f).MustAddCube(&Coord{1, 0, 0}).MustAddCube(&Coord{0, 1, 0}).CanonicalRotation()
 	if s1.Cmp(s2) == 0 {
 		t.Fatalf("Expected two different shapes but got %v and %v", s1, s2)
 	}
 
-	s1 = NewShape(f).MustAddCube(&Coord{1, 0, 0}).MustAddCube(&Coord{2, 0, 0}).MustAddCube(&Coord{0, 1, 0}).MustAddCube(&Coord{0, 0, 1}).WithSmallestScore()
-	s2 = NewShape(f).MustAddCube(&Coord{1, 0, 0}).MustAddCube(&Coord{2, 0, 0}).MustAddCube(&Coord{0, -1, 0}).MustAddCube(&Coord{0, 0, 1}).WithSmallestScore()
+	s1 = NewShape(f).MustAddCube(&Coord{1, 0, 0}).MustAddCube(&Coord{2, 0, 0}).MustAddCube(&Coord{0, 1, 0}).MustAddCube(&Coord{0, 0, 1}).CanonicalRotation()
+	s2 = NewShape(f).MustAddCube(&Coord{1, 0, 0}).MustAddCube(&Coord{2, 0, 0}).MustAddCube(&Coord{0, -1, 0}).MustAddCube(&Coord{0, 0, 1}).CanonicalRotation()
 	if s1.Cmp(s2) != 0 {
 		t.Fatalf("Expected two equal shapes but got %v and %v", s1, s2)
 	}
@@ -23,8 +23,74 @@ func TestCmp(t *testing.T) {
 
 func TestLongestStraight(t *testing.T) {
 	var f func() Shapes
-	s1 := NewShape(f).MustAddCube(&Coord{1, 0, 0}).MustAddCube(&Coord{2, 0, 0}).WithSmallestScore()
+	s1 := NewShape(f).MustAddCube(&Coord{1, 0, 0}).MustAddCube(&Coord{2, 0, 0}).CanonicalRotation()
 	if s1.LongestStraight() != 3 {
 		t.Fatalf("Expected longest straight 3 but got %v", s1.LongestStraight())
 	}
 }
+
+func TestShapeFromString(t *testing.T) {
+	var f func() Shapes
+	s1 := NewShape(f).MustAddCube(&Coord{-1, 0, 0}).MustAddCube(&Coord{-1, 1, 0}).MustAddCube(&Coord{0, 0, -1})
+	s2, err := ShapeFromString(s1.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s1.String() != s2.String() || s1.Cmp(s2) != 0 {
+		t.Fatalf("Expected %v but got %v", s1, s2)
+	}
+	if !s2.Contains(Coord{-1, 1, 0}) || s2.Contains(Coord{1, 0, 0}) {
+		t.Fatalf("Expected %v to contain [-1 1 0] and not [1 0 0]", s2)
+	}
+
+	s3 := s2.MustRemoveCube(&Coord{0, 0, -1})
+	if s3.Size() != 3 || s3.Contains(Coord{0, 0, -1}) || s2.Size() != 4 {
+		t.Fatalf("Expected [0 0 -1] to be removed from a copy of %v but got %v", s2, s3)
+	}
+}
+
+func TestShapeFromStringErrors(t *testing.T) {
+	for _, s := range []string{"", "[0 0 0], ", "[0 0 0", "0 0 0]", "[0 0]", "[0 0 0 0 0]", "[0 x 0]", "[0 0 100000]"} {
+		if _, err := ShapeFromString(s); err == nil {
+			t.Fatalf("Expected an error for %q", s)
+		}
+	}
+}
+
+func TestShapeFromCoords(t *testing.T) {
+	s, err := ShapeFromCoords([]Coord{{0, 1, 0}, {0, 0, 0}, {0, 1, 0}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.String() != "[0 0 0], [0 1 0]" {
+		t.Fatalf("Expected a shape with 2 cubes but got %v", s)
+	}
+
+	for _, coords := range [][]Coord{nil, {{0, 0, 100000}}} {
+		if _, err := ShapeFromCoords(coords); err == nil {
+			t.Fatalf("Expected an error for %v", coords)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	s, err := ShapeFromString("[0 0 0], [1 1 0]")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Validate() == nil {
+		t.Fatalf("Expected %v not to be connected using face adjacency", s)
+	}
+	s.SetAdjacency(Edge)
+	if err := s.Validate(); err != nil {
+		t.Fatalf("Expected %v to be connected using edge adjacency: %v", s, err)
+	}
+	s.SetDimensions(2)
+	if err := s.Validate(); err != nil {
+		t.Fatalf("Expected %v to be a shape with 2 dimensions: %v", s, err)
+	}
+	s.SetDimensions(1)
+	if s.Validate() == nil {
+		t.Fatalf("Expected %v not to be a shape with 1 dimension", s)
+	}
+}
@@ -1,10 +1,152 @@
 package shape
 
+import (
+	"io"
+	"sort"
+)
+
 type Shapes interface {
 	Len() int
 	Add(shape Shape) Shapes
 	Merge(other Shapes) Shapes
 	GetAll() map[Score]*Shape
 	GetAllWithSize(size ShapeSize) map[Score]*Shape
+	// Contains returns true if the shapes contain the shape in the canonical transformation under the equivalence
+	Contains(shape *Shape) bool
+	// Get returns the shape with the score, nil if the shapes don't contain the score
+	Get(score Score) *Shape
+	// Iterate calls fn for every shape with the size ordered by score, the smallest score first, until fn returns false
+	Iterate(size ShapeSize, fn func(shape *Shape) bool)
+	// Filter returns new shapes of the same type with the shapes for which predicate returns true
+	Filter(predicate func(shape *Shape) bool) Shapes
+	// SortedBy returns all shapes ordered by the property, the smallest value first, shapes with the same value are
+	// ordered by size and then by score
+	SortedBy(property func(shape *Shape) float64) []*Shape
 	MaxSize() ShapeSize
+	Equivalence() Equivalence
+	SetEquivalence(equivalence Equivalence) Shapes
+}
+
+// CloseShapes releases the resources that are kept by shapes, like the run files of ShapesDisk
+func CloseShapes(shapes Shapes) {
+	if c, ok := shapes.(io.Closer); ok {
+		if err := c.Close(); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// IterateAll calls fn for every shape ordered by size and then by score until fn returns false, the shapes are never all
+// collected in memory
+func IterateAll(s Shapes, fn func(shape *Shape) bool) {
+	for size := ShapeSize(1); size <= s.MaxSize(); size++ {
+		stopped := false
+		s.Iterate(size, func(shape *Shape) bool {
+			stopped = !fn(shape)
+			return !stopped
+		})
+		if stopped {
+			return
+		}
+	}
+}
+
+// CountWithSize returns the number of shapes with the size, the shapes are counted one at a time so they are never all
+// collected in memory
+func CountWithSize(s Shapes, size ShapeSize) int {
+	result := 0
+	iterateUnordered(s, size, func(shape *Shape) bool {
+		result++
+		return true
+	})
+
+	return result
+}
+
+// shapes that can call fn for the shapes with the size in any order, which is cheaper than Iterate because the shapes
+// aren't sorted
+type unorderedShapes interface {
+	iterateUnordered(size ShapeSize, fn func(shape *Shape) bool)
+}
+
+// calls fn for the shapes with the size in any order until fn returns false, Iterate is used if s can't do it cheaper
+func iterateUnordered(s Shapes, size ShapeSize, fn func(shape *Shape) bool) {
+	if u, ok := s.(unorderedShapes); ok {
+		u.iterateUnordered(size, fn)
+		return
+	}
+	s.Iterate(size, fn)
+}
+
+// adds the shapes of other to s one size at a time, the order doesn't matter so the shapes aren't sorted
+func mergeShapes(s Shapes, other Shapes) Shapes {
+	for size := ShapeSize(1); size <= other.MaxSize(); size++ {
+		iterateUnordered(other, size, func(shape *Shape) bool {
+			s.Add(*shape)
+			return true
+		})
+	}
+
+	return s
+}
+
+// NewShapesWithEquivalence returns a function that creates Shapes using newShapes with the specified equivalence
+func NewShapesWithEquivalence(newShapes func() Shapes, equivalence Equivalence) func() Shapes {
+	return func() Shapes {
+		return newShapes().SetEquivalence(equivalence)
+	}
+}
+
+// returns true if s contains the shape in the canonical transformation under the equivalence of s
+func containsShape(s Shapes, shape *Shape) bool {
+	return s.Get(shape.Canonical(s.Equivalence()).Score()) != nil
+}
+
+// adds the shapes of s for which predicate returns true to result, one size at a time
+func filterShapes(s Shapes, result Shapes, predicate func(shape *Shape) bool) Shapes {
+	IterateAll(s, func(shape *Shape) bool {
+		if predicate(shape) {
+			result.Add(*shape)
+		}
+		return true
+	})
+
+	return result
+}
+
+// returns the shapes of s ordered by the property, the property is calculated once for every shape
+func sortShapesBy(s Shapes, property func(shape *Shape) float64) []*Shape {
+	shapes := make([]*Shape, 0)
+	values := make([]float64, 0)
+	IterateAll(s, func(shape *Shape) bool {
+		shapes = append(shapes, shape)
+		values = append(values, property(shape))
+		return true
+	})
+
+	// the shapes are already ordered by size and score, a stable sort keeps that order for equal values
+	indexes := make([]int, len(shapes))
+	for i := range indexes {
+		indexes[i] = i
+	}
+	sort.SliceStable(indexes, func(i, j int) bool {
+		return values[indexes[i]] < values[indexes[j]]
+	})
+
+	result := make([]*Shape, 0, len(shapes))
+	for _, i := range indexes {
+		result = append(result, shapes[i])
+	}
+
+	return result
+}
+
+// calls fn for the shapes ordered by score until fn returns false, scores is sorted in place
+func iterateScores(scores []Score, get func(score Score) *Shape, fn func(shape *Shape) bool) {
+	sort.Slice(scores, func(i, j int) bool { return scores[i] < scores[j] })
+	for _, score := range scores {
+		if !fn(get(score)) {
+			return
+		}
+	}
 }
@@ -0,0 +1,195 @@
+package shape
+
+import (
+	"hash/fnv"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// DefaultStripes is the number of stripes of ShapesConcurrent created by NewShapesConcurrent
+const DefaultStripes = 64
+
+// ShapesConcurrent can be used by many goroutines at the same time. The shapes are partitioned over stripes using the
+// hash of their score and every stripe has its own lock, so goroutines adding different shapes rarely wait for each
+// other.
+type ShapesConcurrent struct {
+	stripes     []shapesStripe
+	maxSize     int64 // updated atomically
+	equivalence Equivalence
+}
+
+type shapesStripe struct {
+	mu sync.RWMutex
+	s  map[ShapeSize]map[Score]*Shape
+}
+
+func NewShapesConcurrent() Shapes {
+	return NewShapesConcurrentWithStripes(DefaultStripes)()
+}
+
+// NewShapesConcurrentWithStripes returns a function that creates ShapesConcurrent with the specified number of stripes
+func NewShapesConcurrentWithStripes(stripes int) func() Shapes {
+	return func() Shapes {
+		result := &ShapesConcurrent{stripes: make([]shapesStripe, stripes)}
+		for i := range result.stripes {
+			result.stripes[i].s = make(map[ShapeSize]map[Score]*Shape)
+		}
+
+		return result
+	}
+}
+
+// returns the stripe the score belongs to
+func (s *ShapesConcurrent) stripe(score Score) *shapesStripe {
+	h := fnv.New32a()
+	h.Write([]byte(score))
+
+	return &s.stripes[h.Sum32()%uint32(len(s.stripes))]
+}
+
+func (s *ShapesConcurrent) Len() int {
+	result := 0
+	for i := range s.stripes {
+		stripe := &s.stripes[i]
+		stripe.mu.RLock()
+		for _, shapes := range stripe.s {
+			result += len(shapes)
+		}
+		stripe.mu.RUnlock()
+	}
+
+	return result
+}
+
+func (s *ShapesConcurrent) Add(shape Shape) Shapes {
+	shapeSize := shape.Size()
+	score := shape.Score()
+
+	stripe := s.stripe(score)
+	stripe.mu.Lock()
+	if _, ok := stripe.s[shapeSize]; !ok {
+		stripe.s[shapeSize] = make(map[Score]*Shape)
+	}
+	stripe.s[shapeSize][score] = &shape
+	stripe.mu.Unlock()
+
+	for {
+		maxSize := atomic.LoadInt64(&s.maxSize)
+		if int64(shapeSize) <= maxSize || atomic.CompareAndSwapInt64(&s.maxSize, maxSize, int64(shapeSize)) {
+			break
+		}
+	}
+
+	return s
+}
+
+func (s *ShapesConcurrent) GetAll() map[Score]*Shape {
+	result := make(map[Score]*Shape)
+
+	for i := range s.stripes {
+		stripe := &s.stripes[i]
+		stripe.mu.RLock()
+		for _, shapes := range stripe.s {
+			for score, shape := range shapes {
+				result[score] = shape
+			}
+		}
+		stripe.mu.RUnlock()
+	}
+
+	return result
+}
+
+func (s *ShapesConcurrent) GetAllWithSize(size ShapeSize) map[Score]*Shape {
+	result := make(map[Score]*Shape)
+
+	for i := range s.stripes {
+		stripe := &s.stripes[i]
+		stripe.mu.RLock()
+		for score, shape := range stripe.s[size] {
+			result[score] = shape
+		}
+		stripe.mu.RUnlock()
+	}
+
+	return result
+}
+
+func (s *ShapesConcurrent) Contains(shape *Shape) bool {
+	return containsShape(s, shape)
+}
+
+func (s *ShapesConcurrent) Get(score Score) *Shape {
+	stripe := s.stripe(score)
+	stripe.mu.RLock()
+	defer stripe.mu.RUnlock()
+
+	for _, shapes := range stripe.s {
+		if shape, ok := shapes[score]; ok {
+			return shape
+		}
+	}
+
+	return nil
+}
+
+// Iterate collects the shapes with the size from every stripe before fn is called, so fn can add shapes
+func (s *ShapesConcurrent) Iterate(size ShapeSize, fn func(shape *Shape) bool) {
+	shapes := s.withSize(size)
+	sort.Slice(shapes, func(i, j int) bool { return shapes[i].Score() < shapes[j].Score() })
+	for _, shape := range shapes {
+		if !fn(shape) {
+			return
+		}
+	}
+}
+
+// like Iterate without sorting the shapes
+func (s *ShapesConcurrent) iterateUnordered(size ShapeSize, fn func(shape *Shape) bool) {
+	for _, shape := range s.withSize(size) {
+		if !fn(shape) {
+			return
+		}
+	}
+}
+
+// returns the shapes with the size of every stripe, the stripes are unlocked when the shapes are returned
+func (s *ShapesConcurrent) withSize(size ShapeSize) []*Shape {
+	result := make([]*Shape, 0)
+	for i := range s.stripes {
+		stripe := &s.stripes[i]
+		stripe.mu.RLock()
+		for _, shape := range stripe.s[size] {
+			result = append(result, shape)
+		}
+		stripe.mu.RUnlock()
+	}
+
+	return result
+}
+
+func (s *ShapesConcurrent) Filter(predicate func(shape *Shape) bool) Shapes {
+	return filterShapes(s, NewShapesConcurrentWithStripes(len(s.stripes))().SetEquivalence(s.equivalence), predicate)
+}
+
+func (s *ShapesConcurrent) SortedBy(property func(shape *Shape) float64) []*Shape {
+	return sortShapesBy(s, property)
+}
+
+func (s *ShapesConcurrent) Merge(other Shapes) Shapes {
+	return mergeShapes(s, other)
+}
+
+func (s *ShapesConcurrent) MaxSize() ShapeSize {
+	return ShapeSize(atomic.LoadInt64(&s.maxSize))
+}
+
+func (s *ShapesConcurrent) Equivalence() Equivalence {
+	return s.equivalence
+}
+
+func (s *ShapesConcurrent) SetEquivalence(equivalence Equivalence) Shapes {
+	s.equivalence = equivalence
+	return s
+}
@@ -0,0 +1,72 @@
+package shape_test
+
+import (
+	"sync"
+	"testing"
+
+	. "github.com/munnik/cubes/shape"
+)
+
+func TestAddConcurrent(t *testing.T) {
+	shapes := NewShapesConcurrent()
+
+	var f func() Shapes
+	s1 := NewShape(f).MustAddCube(&Coord{1, 0, 0}).MustAddCube(&Coord{2, 0, 0})
+	s2 := NewShape(f).MustAddCube(&Coord{1, 0, 0}).MustAddCube(&Coord{0, 1, 0})
+	s3 := NewShape(f).MustAddCube(&Coord{1, 0, 0}).MustAddCube(&Coord{0, 1, 0})
+
+	shapes.Add(*s1).Add(*s2).Add(*s3)
+
+	if shapes.Len() != 2 {
+		t.Fatalf("Expected length to equal 2 but got %d", shapes.Len())
+	}
+}
+
+func TestMergeConcurrent(t *testing.T) {
+	shapes1 := NewShapesConcurrent()
+	shapes2 := NewShapesConcurrent()
+
+	var f func() Shapes
+	s1 := NewShape(f).MustAddCube(&Coord{1, 0, 0}).MustAddCube(&Coord{2, 0, 0})
+	s2 := NewShape(f).MustAddCube(&Coord{1, 0, 0}).MustAddCube(&Coord{0, 1, 0})
+	s3 := NewShape(f).MustAddCube(&Coord{1, 0, 0}).MustAddCube(&Coord{0, 0, 1})
+
+	shapes1.Add(*s1).Add(*s2)
+	shapes2.Add(*s2).Add(*s3)
+
+	shapes1.Merge(shapes2)
+
+	if shapes1.Len() != 3 {
+		t.Fatalf("Expected length to equal 3 but got %d", shapes1.Len())
+	}
+}
+
+func TestAddConcurrentFromGoroutines(t *testing.T) {
+	expected := NewPool(1).KeepGrowing([]*Shape{NewShape(NewShapesDefaultMap)}, 6)
+	all := make([]*Shape, 0, expected.Len())
+	for _, shape := range expected.GetAll() {
+		all = append(all, shape)
+	}
+
+	shapes := NewShapesConcurrentWithStripes(4)()
+	wg := sync.WaitGroup{}
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := range all {
+				shapes.Add(*all[(i*31+j)%len(all)])
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if shapes.Len() != expected.Len() || shapes.MaxSize() != expected.MaxSize() {
+		t.Fatalf("Expected %d shapes up to size %d but got %d shapes up to size %d", expected.Len(), expected.MaxSize(), shapes.Len(), shapes.MaxSize())
+	}
+	for size := ShapeSize(1); size <= expected.MaxSize(); size++ {
+		if len(shapes.GetAllWithSize(size)) != len(expected.GetAllWithSize(size)) {
+			t.Fatalf("Expected %d shapes with size %d but got %d", len(expected.GetAllWithSize(size)), size, len(shapes.GetAllWithSize(size)))
+		}
+	}
+}
@@ -1,8 +1,9 @@
 package shape
 
 type ShapesDefaultMap struct {
-	s       map[ShapeSize]map[Score]*Shape
-	maxSize ShapeSize
+	s           map[ShapeSize]map[Score]*Shape
+	maxSize     ShapeSize
+	equivalence Equivalence
 }
 
 func NewShapesDefaultMap() Shapes {
@@ -51,14 +52,58 @@ func (s ShapesDefaultMap) GetAllWithSize(size ShapeSize) map[Score]*Shape {
 	return s.s[size]
 }
 
-func (s *ShapesDefaultMap) Merge(other Shapes) Shapes {
-	for _, shape := range other.GetAll() {
-		s.Add(*shape)
+func (s *ShapesDefaultMap) Contains(shape *Shape) bool {
+	return containsShape(s, shape)
+}
+
+func (s ShapesDefaultMap) Get(score Score) *Shape {
+	for _, shapes := range s.s {
+		if shape, ok := shapes[score]; ok {
+			return shape
+		}
 	}
 
-	return s
+	return nil
+}
+
+func (s ShapesDefaultMap) Iterate(size ShapeSize, fn func(shape *Shape) bool) {
+	shapes := s.s[size]
+	scores := make([]Score, 0, len(shapes))
+	for score := range shapes {
+		scores = append(scores, score)
+	}
+	iterateScores(scores, func(score Score) *Shape { return shapes[score] }, fn)
+}
+
+func (s ShapesDefaultMap) iterateUnordered(size ShapeSize, fn func(shape *Shape) bool) {
+	for _, shape := range s.s[size] {
+		if !fn(shape) {
+			return
+		}
+	}
+}
+
+func (s *ShapesDefaultMap) Filter(predicate func(shape *Shape) bool) Shapes {
+	return filterShapes(s, NewShapesDefaultMap().SetEquivalence(s.equivalence), predicate)
+}
+
+func (s *ShapesDefaultMap) SortedBy(property func(shape *Shape) float64) []*Shape {
+	return sortShapesBy(s, property)
+}
+
+func (s *ShapesDefaultMap) Merge(other Shapes) Shapes {
+	return mergeShapes(s, other)
 }
 
 func (s ShapesDefaultMap) MaxSize() ShapeSize {
 	return s.maxSize
 }
+
+func (s ShapesDefaultMap) Equivalence() Equivalence {
+	return s.equivalence
+}
+
+func (s *ShapesDefaultMap) SetEquivalence(equivalence Equivalence) Shapes {
+	s.equivalence = equivalence
+	return s
+}
@@ -0,0 +1,520 @@
+package shape
+
+import (
+	"bufio"
+	"container/heap"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+)
+
+// DefaultDiskBudget is the number of bytes ShapesDisk keeps in memory before the scores are written to disk
+const DefaultDiskBudget = 1 << 30
+
+// estimation of the memory used by a score in the buffer besides the bytes of the score itself
+const diskEntryOverhead = 64
+
+// the maximum number of runs that are merged at the same time, so the number of open files is limited. When there are
+// more runs they are merged in several passes.
+const diskMergeFanIn = 64
+
+// ShapesDisk keeps the scores of the shapes in memory until the memory budget is used, then the scores are sorted and
+// written to run files on disk, one run per size. When the shapes of a size are needed all runs of that size are merged
+// into one run without duplicates, like an external merge sort. Shapes are created from the score when they are
+// needed, so the shapes returned always have positive coordinates. Call Close to remove the run files.
+//
+// The methods of Shapes can't return an error, when writing or reading a run file fails the first error is kept and the
+// shapes are incomplete from then on. The error is returned by Err and Close.
+type ShapesDisk struct {
+	budget      int64
+	used        int64
+	buffer      map[ShapeSize]map[Score]struct{}
+	runs        map[ShapeSize][]diskRun
+	dir         string // created when the first run is written
+	maxSize     ShapeSize
+	equivalence Equivalence
+	newShapes   func() Shapes
+	dimensions  int       // the dimensions of the first shape that is added, all shapes should have the same dimensions
+	adjacency   Adjacency // the adjacency of the first shape that is added
+	err         error     // the first error writing or reading the run files
+}
+
+// a sorted file of scores without duplicates, every score has the same length
+type diskRun struct {
+	path  string
+	count int
+}
+
+func NewShapesDisk() Shapes {
+	return NewShapesDiskWithBudget(DefaultDiskBudget)()
+}
+
+// NewShapesDiskWithBudget returns a function that creates ShapesDisk that keep at most budget bytes in memory
+func NewShapesDiskWithBudget(budget int64) func() Shapes {
+	return func() Shapes {
+		return &ShapesDisk{
+			budget: budget,
+			buffer: make(map[ShapeSize]map[Score]struct{}),
+			runs:   make(map[ShapeSize][]diskRun),
+		}
+	}
+}
+
+func (s *ShapesDisk) Len() int {
+	result := 0
+	for size := ShapeSize(1); size <= s.maxSize; size++ {
+		if len(s.runs[size]) == 0 {
+			result += len(s.buffer[size])
+			continue
+		}
+		if run, ok := s.compacted(size); ok {
+			result += run.count
+		}
+	}
+
+	return result
+}
+
+func (s *ShapesDisk) Add(shape Shape) Shapes {
+	if s.newShapes == nil {
+		s.newShapes = shape.newShapes
+	}
+	if s.dimensions == 0 {
+		s.dimensions = shape.Dimensions()
+		s.adjacency = shape.Adjacency()
+	}
+
+	shapeSize := shape.Size()
+	if _, ok := s.buffer[shapeSize]; !ok {
+		s.buffer[shapeSize] = make(map[Score]struct{})
+		if shapeSize > s.maxSize {
+			s.maxSize = shapeSize
+		}
+	}
+	score := shape.Score()
+	if _, ok := s.buffer[shapeSize][score]; !ok {
+		s.buffer[shapeSize][score] = struct{}{}
+		s.used += int64(len(score)) + diskEntryOverhead
+	}
+
+	if s.used > s.budget && s.err == nil {
+		s.fail(s.spill())
+	}
+
+	return s
+}
+
+func (s *ShapesDisk) GetAll() map[Score]*Shape {
+	result := make(map[Score]*Shape)
+
+	for size := ShapeSize(1); size <= s.maxSize; size++ {
+		for score, shape := range s.GetAllWithSize(size) {
+			result[score] = shape
+		}
+	}
+
+	return result
+}
+
+func (s *ShapesDisk) GetAllWithSize(size ShapeSize) map[Score]*Shape {
+	result := make(map[Score]*Shape)
+	s.Iterate(size, func(shape *Shape) bool {
+		result[shape.Score()] = shape
+		return true
+	})
+
+	return result
+}
+
+func (s *ShapesDisk) Contains(shape *Shape) bool {
+	return containsShape(s, shape)
+}
+
+// Get looks the score up in the buffer and in the run of the size of the score, the run is searched on disk
+func (s *ShapesDisk) Get(score Score) *Shape {
+	for size := ShapeSize(1); size <= s.maxSize; size++ {
+		if s.recordLength(size) != len(score) {
+			continue
+		}
+		if _, ok := s.buffer[size][score]; ok {
+			return s.mustShapeFromScore(score)
+		}
+		if len(s.runs[size]) == 0 {
+			return nil
+		}
+		run, ok := s.compacted(size)
+		if !ok {
+			return nil
+		}
+		ok, err := searchRun(run, score)
+		s.fail(err)
+		if ok {
+			return s.mustShapeFromScore(score)
+		}
+		return nil
+	}
+
+	return nil
+}
+
+// Iterate reads the shapes from the run of the size one at a time, so the shapes of the size don't have to fit in memory
+func (s *ShapesDisk) Iterate(size ShapeSize, fn func(shape *Shape) bool) {
+	if len(s.runs[size]) == 0 {
+		scores := make([]Score, 0, len(s.buffer[size]))
+		for score := range s.buffer[size] {
+			scores = append(scores, score)
+		}
+		iterateScores(scores, s.mustShapeFromScore, fn)
+		return
+	}
+
+	run, ok := s.compacted(size)
+	if !ok {
+		return
+	}
+	err := readRun(run, s.recordLength(size), func(score Score) error {
+		if !fn(s.mustShapeFromScore(score)) {
+			return errStopIterating
+		}
+		return nil
+	})
+	if err != errStopIterating {
+		s.fail(err)
+	}
+}
+
+func (s *ShapesDisk) Filter(predicate func(shape *Shape) bool) Shapes {
+	return filterShapes(s, NewShapesDiskWithBudget(s.budget)().SetEquivalence(s.equivalence), predicate)
+}
+
+func (s *ShapesDisk) SortedBy(property func(shape *Shape) float64) []*Shape {
+	return sortShapesBy(s, property)
+}
+
+// returns the shape the score is created from with the properties of the shapes that are added
+func (s *ShapesDisk) mustShapeFromScore(score Score) *Shape {
+	result, err := ShapeFromScore(score, s.dimensions)
+	if err != nil {
+		panic(err)
+	}
+	result.newShapes = s.newShapes
+	result.adjacency = s.adjacency
+
+	return result
+}
+
+// Merge adds the shapes of other one size at a time, so only the shapes of one size of other are in memory
+func (s *ShapesDisk) Merge(other Shapes) Shapes {
+	return mergeShapes(s, other)
+}
+
+func (s *ShapesDisk) MaxSize() ShapeSize {
+	return s.maxSize
+}
+
+func (s *ShapesDisk) Equivalence() Equivalence {
+	return s.equivalence
+}
+
+func (s *ShapesDisk) SetEquivalence(equivalence Equivalence) Shapes {
+	s.equivalence = equivalence
+	return s
+}
+
+// Err returns the first error writing or reading the run files, nil if there was no error
+func (s *ShapesDisk) Err() error {
+	return s.err
+}
+
+// keeps err if it is the first error
+func (s *ShapesDisk) fail(err error) {
+	if s.err == nil {
+		s.err = err
+	}
+}
+
+// Close removes the run files and returns the first error writing or reading them, the shapes can't be used after
+// closing
+func (s *ShapesDisk) Close() error {
+	s.buffer = make(map[ShapeSize]map[Score]struct{})
+	s.runs = make(map[ShapeSize][]diskRun)
+	s.used = 0
+	err := s.err
+	s.err = nil
+	if s.dir == "" {
+		return err
+	}
+	dir := s.dir
+	s.dir = ""
+
+	return errors.Join(err, os.RemoveAll(dir))
+}
+
+// returns the number of bytes of the score of a shape with the specified size
+func (s *ShapesDisk) recordLength(size ShapeSize) int {
+	return ScoreLength(size, s.dimensions)
+}
+
+// spill writes the scores in the buffer to a new run for every size and empties the buffer
+func (s *ShapesDisk) spill() error {
+	for size, scores := range s.buffer {
+		if len(scores) == 0 {
+			continue
+		}
+		sorted := make([]string, 0, len(scores))
+		for score := range scores {
+			sorted = append(sorted, string(score))
+		}
+		sort.Strings(sorted)
+
+		i := 0
+		run, err := s.writeRun(func() (Score, bool) {
+			if i == len(sorted) {
+				return "", false
+			}
+			i++
+			return Score(sorted[i-1]), true
+		})
+		if err != nil {
+			return err
+		}
+		s.runs[size] = append(s.runs[size], run)
+		s.buffer[size] = make(map[Score]struct{})
+	}
+	s.used = 0
+
+	return nil
+}
+
+// writeRun writes the scores returned by next to a new run file until next returns false, the scores should be sorted
+// and duplicates are skipped
+func (s *ShapesDisk) writeRun(next func() (Score, bool)) (diskRun, error) {
+	if s.dir == "" {
+		dir, err := os.MkdirTemp("", "cubes-shapes-")
+		if err != nil {
+			return diskRun{}, err
+		}
+		s.dir = dir
+	}
+
+	f, err := os.CreateTemp(s.dir, "run-")
+	if err != nil {
+		return diskRun{}, err
+	}
+	defer f.Close()
+
+	result := diskRun{path: f.Name()}
+	w := bufio.NewWriter(f)
+	var previous Score
+	for score, ok := next(); ok; score, ok = next() {
+		if result.count > 0 && score == previous {
+			continue
+		}
+		if _, err := w.WriteString(string(score)); err != nil {
+			return diskRun{}, err
+		}
+		previous = score
+		result.count++
+	}
+	if err := w.Flush(); err != nil {
+		return diskRun{}, err
+	}
+
+	return result, f.Close()
+}
+
+// returns the run with all shapes of the size, false if the shapes can't be compacted because of an error
+func (s *ShapesDisk) compacted(size ShapeSize) (diskRun, bool) {
+	if s.err != nil {
+		return diskRun{}, false
+	}
+	result, err := s.compact(size)
+	if err != nil {
+		s.fail(err)
+		return diskRun{}, false
+	}
+
+	return result, true
+}
+
+// compact writes the buffer to disk and merges all runs of the size into one run without duplicates. At most
+// diskMergeFanIn runs are merged at the same time, the merged runs are merged again until one run is left.
+func (s *ShapesDisk) compact(size ShapeSize) (diskRun, error) {
+	if len(s.buffer[size]) > 0 {
+		if err := s.spill(); err != nil {
+			return diskRun{}, err
+		}
+	}
+
+	for len(s.runs[size]) > 1 {
+		runs := s.runs[size]
+		merged := make([]diskRun, 0, (len(runs)+diskMergeFanIn-1)/diskMergeFanIn)
+		for start := 0; start < len(runs); start += diskMergeFanIn {
+			end := start + diskMergeFanIn
+			if end > len(runs) {
+				end = len(runs)
+			}
+			run, err := s.mergeRuns(runs[start:end], s.recordLength(size))
+			if err != nil {
+				return diskRun{}, err
+			}
+			merged = append(merged, run)
+		}
+		s.runs[size] = merged
+	}
+
+	return s.runs[size][0], nil
+}
+
+// mergeRuns merges the runs into one new run without duplicates and removes the runs, all runs are open at the same time
+func (s *ShapesDisk) mergeRuns(runs []diskRun, length int) (diskRun, error) {
+	if len(runs) == 1 {
+		return runs[0], nil
+	}
+
+	h := make(runHeap, 0, len(runs))
+	for _, run := range runs {
+		f, err := os.Open(run.path)
+		if err != nil {
+			return diskRun{}, err
+		}
+		defer f.Close()
+		r := &runReader{r: bufio.NewReader(f), record: make([]byte, length)}
+		ok, err := r.next()
+		if err != nil {
+			return diskRun{}, err
+		}
+		if ok {
+			h = append(h, r)
+		}
+	}
+	heap.Init(&h)
+
+	var readErr error
+	result, err := s.writeRun(func() (Score, bool) {
+		if len(h) == 0 || readErr != nil {
+			return "", false
+		}
+		r := h[0]
+		score := Score(r.record)
+		ok, err := r.next()
+		if err != nil {
+			readErr = err
+			return "", false
+		}
+		if ok {
+			heap.Fix(&h, 0)
+		} else {
+			heap.Pop(&h)
+		}
+		return score, true
+	})
+	if err == nil {
+		err = readErr
+	}
+	if err != nil {
+		return diskRun{}, err
+	}
+
+	for _, run := range runs {
+		if err := os.Remove(run.path); err != nil {
+			return diskRun{}, err
+		}
+	}
+
+	return result, nil
+}
+
+// returned by the function called by readRun to stop reading the run
+var errStopIterating = errors.New("stop iterating")
+
+// searchRun returns true if the run contains the score, the run is searched with a binary search because the scores are
+// sorted and have the same length
+func searchRun(run diskRun, score Score) (bool, error) {
+	f, err := os.Open(run.path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	record := make([]byte, len(score))
+	var readErr error
+	index := sort.Search(run.count, func(i int) bool {
+		if readErr != nil {
+			return true
+		}
+		if _, readErr = f.ReadAt(record, int64(i)*int64(len(record))); readErr != nil {
+			return true
+		}
+		return string(record) >= string(score)
+	})
+	if readErr != nil {
+		return false, readErr
+	}
+	if index == run.count {
+		return false, nil
+	}
+	if _, err := f.ReadAt(record, int64(index)*int64(len(record))); err != nil {
+		return false, err
+	}
+
+	return string(record) == string(score), nil
+}
+
+// readRun calls fn for every score in the run
+func readRun(run diskRun, length int, fn func(score Score) error) error {
+	f, err := os.Open(run.path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	r := &runReader{r: bufio.NewReader(f), record: make([]byte, length)}
+	for {
+		ok, err := r.next()
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return nil
+		}
+		if err := fn(Score(r.record)); err != nil {
+			return err
+		}
+	}
+}
+
+// runReader reads the scores of a run one at a time
+type runReader struct {
+	r      *bufio.Reader
+	record []byte // the current score
+}
+
+// next reads the next score into record, returns false at the end of the run
+func (r *runReader) next() (bool, error) {
+	_, err := io.ReadFull(r.r, r.record)
+	if err == io.EOF {
+		return false, nil
+	}
+	if err == io.ErrUnexpectedEOF {
+		return false, fmt.Errorf("run ends with an incomplete score")
+	}
+
+	return err == nil, err
+}
+
+// runHeap orders the run readers by their current score, the smallest score first
+type runHeap []*runReader
+
+func (h runHeap) Len() int           { return len(h) }
+func (h runHeap) Less(i, j int) bool { return string(h[i].record) < string(h[j].record) }
+func (h runHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *runHeap) Push(x any)        { *h = append(*h, x.(*runReader)) }
+func (h *runHeap) Pop() any {
+	old := *h
+	result := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return result
+}
@@ -0,0 +1,134 @@
+package shape_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/munnik/cubes/shape"
+)
+
+func TestAddDisk(t *testing.T) {
+	for _, budget := range []int64{0, DefaultDiskBudget} {
+		shapes := NewShapesDiskWithBudget(budget)()
+
+		var f func() Shapes
+		s1 := NewShape(f).MustAddCube(&Coord{1, 0, 0}).MustAddCube(&Coord{2, 0, 0})
+		s2 := NewShape(f).MustAddCube(&Coord{1, 0, 0}).MustAddCube(&Coord{0, 1, 0})
+		s3 := NewShape(f).MustAddCube(&Coord{1, 0, 0}).MustAddCube(&Coord{0, 1, 0})
+
+		shapes.Add(*s1).Add(*s2).Add(*s3)
+
+		if shapes.Len() != 2 {
+			t.Fatalf("Expected length to equal 2 with budget %d but got %d", budget, shapes.Len())
+		}
+		if err := shapes.(*ShapesDisk).Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestMergeDisk(t *testing.T) {
+	for _, budget := range []int64{0, DefaultDiskBudget} {
+		shapes1 := NewShapesDiskWithBudget(budget)()
+		shapes2 := NewShapesDefaultMap()
+
+		var f func() Shapes
+		s1 := NewShape(f).MustAddCube(&Coord{1, 0, 0}).MustAddCube(&Coord{2, 0, 0})
+		s2 := NewShape(f).MustAddCube(&Coord{1, 0, 0}).MustAddCube(&Coord{0, 1, 0})
+		s3 := NewShape(f).MustAddCube(&Coord{1, 0, 0}).MustAddCube(&Coord{0, 0, 1})
+
+		shapes1.Add(*s1).Add(*s2)
+		shapes2.Add(*s2).Add(*s3)
+
+		shapes1.Merge(shapes2)
+
+		if shapes1.Len() != 3 {
+			t.Fatalf("Expected length to equal 3 with budget %d but got %d", budget, shapes1.Len())
+		}
+		if err := shapes1.(*ShapesDisk).Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestGetAllWithSizeDisk(t *testing.T) {
+	grown := NewPool(1).KeepGrowing([]*Shape{NewShape(NewShapesDefaultMap)}, 6)
+
+	// a small budget writes many runs that all have to be merged
+	shapes := NewShapesDiskWithBudget(1024)()
+	defer shapes.(*ShapesDisk).Close()
+	expected := NewShapesDefaultMap()
+	for _, shape := range grown.GetAll() {
+		for _, s := range []*Shape{shape, shape.MustRotate(XAxis), shape} {
+			shapes.Add(*s)
+			expected.Add(*s)
+		}
+	}
+
+	if shapes.MaxSize() != expected.MaxSize() {
+		t.Fatalf("Expected max size %d but got %d", expected.MaxSize(), shapes.MaxSize())
+	}
+	if shapes.Len() != expected.Len() {
+		t.Fatalf("Expected length to equal %d but got %d", expected.Len(), shapes.Len())
+	}
+	for size := ShapeSize(1); size <= expected.MaxSize(); size++ {
+		all := shapes.GetAllWithSize(size)
+		if len(all) != len(expected.GetAllWithSize(size)) {
+			t.Fatalf("Expected %d shapes with size %d but got %d", len(expected.GetAllWithSize(size)), size, len(all))
+		}
+		for score, shape := range expected.GetAllWithSize(size) {
+			if !shape.Equals(all[score]) {
+				t.Fatalf("Expected shape %v with size %d but got %v", shape, size, all[score])
+			}
+		}
+	}
+}
+
+func TestCompactManyRunsDisk(t *testing.T) {
+	grown := NewPool(1).KeepGrowing([]*Shape{NewShape(NewShapesDefaultMap)}, 6)
+
+	// without a budget every shape is written to its own run, there are more runs than can be merged at once
+	shapes := NewShapesDiskWithBudget(0)()
+	defer shapes.(*ShapesDisk).Close()
+	for _, shape := range grown.GetAll() {
+		shapes.Add(*shape)
+	}
+
+	if shapes.Len() != grown.Len() {
+		t.Fatalf("Expected length to equal %d but got %d", grown.Len(), shapes.Len())
+	}
+	if err := shapes.(*ShapesDisk).Err(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestErrDisk(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("TMPDIR", dir)
+
+	shapes := NewShapesDiskWithBudget(0)()
+	var f func() Shapes
+	shapes.Add(*NewShape(f).MustAddCube(&Coord{1, 0, 0})).Add(*NewShape(f).MustAddCube(&Coord{0, 1, 0}))
+
+	// removing the run files makes merging them fail
+	runs, err := filepath.Glob(filepath.Join(dir, "*"))
+	if err != nil || len(runs) == 0 {
+		t.Fatalf("Expected the runs to be written to %s but got %v and error %v", dir, runs, err)
+	}
+	for _, run := range runs {
+		if err := os.RemoveAll(run); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if shapes.Len() != 0 {
+		t.Fatalf("Expected no shapes after an error but got %d", shapes.Len())
+	}
+	if shapes.(*ShapesDisk).Err() == nil {
+		t.Fatalf("Expected an error after the run files are removed")
+	}
+	if shapes.(*ShapesDisk).Close() == nil {
+		t.Fatalf("Expected Close to return the error")
+	}
+}
@@ -1,26 +1,22 @@
 package shape
 
+import "sort"
+
 type ShapesLongestStraightMap struct {
-	s       [MAX_NUMBER_OF_CUBES][MAX_NUMBER_OF_CUBES]map[Score]*Shape // array of size, array of longest straight, map of score to pointer to shape
-	maxSize ShapeSize
+	s           [][]map[Score]*Shape // slice of size, slice of longest straight, map of score to pointer to shape
+	maxSize     ShapeSize
+	equivalence Equivalence
 }
 
 func NewShapesLongestStraightMap() Shapes {
-	result := &ShapesLongestStraightMap{}
-	for i := ShapeSize(0); i < MAX_NUMBER_OF_CUBES; i++ {
-		for j := ShapeSize(0); j < MAX_NUMBER_OF_CUBES; j++ {
-			result.s[i][j] = make(map[Score]*Shape) // how many shapes do we expect?
-		}
-	}
-
-	return result
+	return &ShapesLongestStraightMap{}
 }
 
 func (s ShapesLongestStraightMap) Len() int {
 	result := 0
-	for i := ShapeSize(0); i < MAX_NUMBER_OF_CUBES; i++ {
-		for j := ShapeSize(0); j < MAX_NUMBER_OF_CUBES; j++ {
-			result += len(s.s[i][j])
+	for _, longestStraights := range s.s {
+		for _, m := range longestStraights {
+			result += len(m)
 		}
 	}
 
@@ -31,6 +27,16 @@ func (s *ShapesLongestStraightMap) Add(shape Shape) Shapes {
 	shapeSize := shape.Size()
 	longesStraight := shape.LongestStraight()
 
+	// the longest straight of a shape is never bigger than its size
+	for ShapeSize(len(s.s)) < shapeSize {
+		size := len(s.s) + 1
+		longestStraights := make([]map[Score]*Shape, size)
+		for i := range longestStraights {
+			longestStraights[i] = make(map[Score]*Shape) // how many shapes do we expect?
+		}
+		s.s = append(s.s, longestStraights)
+	}
+
 	s.s[shapeSize-1][longesStraight-1][shape.Score()] = &shape
 	if shapeSize > s.maxSize {
 		s.maxSize = shapeSize
@@ -53,7 +59,7 @@ func (s ShapesLongestStraightMap) GetAll() map[Score]*Shape {
 func (s ShapesLongestStraightMap) GetAllWithSize(size ShapeSize) map[Score]*Shape {
 	result := make(map[Score]*Shape)
 
-	if size < 1 || size > MAX_NUMBER_OF_CUBES {
+	if size < 1 || size > ShapeSize(len(s.s)) {
 		return result
 	}
 
@@ -66,14 +72,81 @@ func (s ShapesLongestStraightMap) GetAllWithSize(size ShapeSize) map[Score]*Shap
 	return result
 }
 
-func (s *ShapesLongestStraightMap) Merge(other Shapes) Shapes {
-	for _, shape := range other.GetAll() {
-		s.Add(*shape)
+func (s *ShapesLongestStraightMap) Contains(shape *Shape) bool {
+	return containsShape(s, shape)
+}
+
+func (s ShapesLongestStraightMap) Get(score Score) *Shape {
+	for _, longestStraights := range s.s {
+		for _, m := range longestStraights {
+			if shape, ok := m[score]; ok {
+				return shape
+			}
+		}
 	}
 
-	return s
+	return nil
+}
+
+func (s ShapesLongestStraightMap) Iterate(size ShapeSize, fn func(shape *Shape) bool) {
+	if size < 1 || size > ShapeSize(len(s.s)) {
+		return
+	}
+
+	// the scores are collected with the index of the map of their longest straight, so no map is copied
+	type entry struct {
+		score Score
+		m     map[Score]*Shape
+	}
+	entries := make([]entry, 0)
+	for _, m := range s.s[size-1] {
+		for score := range m {
+			entries = append(entries, entry{score: score, m: m})
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].score < entries[j].score })
+	for _, e := range entries {
+		if !fn(e.m[e.score]) {
+			return
+		}
+	}
+}
+
+func (s ShapesLongestStraightMap) iterateUnordered(size ShapeSize, fn func(shape *Shape) bool) {
+	if size < 1 || size > ShapeSize(len(s.s)) {
+		return
+	}
+
+	for _, m := range s.s[size-1] {
+		for _, shape := range m {
+			if !fn(shape) {
+				return
+			}
+		}
+	}
+}
+
+func (s *ShapesLongestStraightMap) Filter(predicate func(shape *Shape) bool) Shapes {
+	return filterShapes(s, NewShapesLongestStraightMap().SetEquivalence(s.equivalence), predicate)
+}
+
+func (s *ShapesLongestStraightMap) SortedBy(property func(shape *Shape) float64) []*Shape {
+	return sortShapesBy(s, property)
+}
+
+func (s *ShapesLongestStraightMap) Merge(other Shapes) Shapes {
+	return mergeShapes(s, other)
 }
 
 func (s ShapesLongestStraightMap) MaxSize() ShapeSize {
 	return s.maxSize
 }
+
+func (s ShapesLongestStraightMap) Equivalence() Equivalence {
+	return s.equivalence
+}
+
+func (s *ShapesLongestStraightMap) SetEquivalence(equivalence Equivalence) Shapes {
+	s.equivalence = equivalence
+	return s
+}
@@ -0,0 +1,74 @@
+package shape_test
+
+import (
+	"io"
+	"testing"
+
+	. "github.com/munnik/cubes/shape"
+)
+
+func TestShapesQuery(t *testing.T) {
+	for name, newShapes := range map[string]func() Shapes{
+		"DefaultMap":         NewShapesDefaultMap,
+		"LongestStraightMap": NewShapesLongestStraightMap,
+		"Concurrent":         NewShapesConcurrent,
+		"Disk":               NewShapesDiskWithBudget(0),
+		"DiskInMemory":       NewShapesDisk,
+	} {
+		newShapes = NewShapesWithEquivalence(newShapes, OneSided)
+		shapes := NewPool(1).KeepGrowing([]*Shape{NewShape(newShapes)}, 5)
+
+		// the shapes with 4 cubes are ordered by score
+		count := 0
+		var previous Score
+		shapes.Iterate(4, func(s *Shape) bool {
+			if count > 0 && s.Score() <= previous {
+				t.Fatalf("Expected the shapes ordered by score using %s", name)
+			}
+			previous = s.Score()
+			count++
+			return true
+		})
+		if count != 8 {
+			t.Fatalf("Expected 8 shapes with size 4 using %s but got %d", name, count)
+		}
+
+		count = 0
+		shapes.Iterate(5, func(s *Shape) bool {
+			count++
+			return count < 3
+		})
+		if count != 3 {
+			t.Fatalf("Expected iterating to stop after 3 shapes using %s but got %d", name, count)
+		}
+
+		var f func() Shapes
+		straight := NewShape(f).MustAddCube(&Coord{0, 1, 0}).MustAddCube(&Coord{0, 2, 0})
+		if !shapes.Contains(straight) || shapes.Contains(straight.MustAddCube(&Coord{0, 3, 0}).MustAddCube(&Coord{0, 4, 0}).MustAddCube(&Coord{0, 5, 0})) {
+			t.Fatalf("Expected to contain %v and not the straight shape with 6 cubes using %s", straight, name)
+		}
+		score := straight.Canonical(OneSided).Score()
+		if s := shapes.Get(score); s == nil || s.Score() != score {
+			t.Fatalf("Expected to get %v using %s but got %v", straight, name, s)
+		}
+		if s := shapes.Get(Score("unknown")); s != nil {
+			t.Fatalf("Expected no shape for an unknown score using %s but got %v", name, s)
+		}
+
+		filtered := shapes.Filter(func(s *Shape) bool { return s.LongestStraight() >= 4 })
+		if filtered.Len() != 4 || filtered.Equivalence() != OneSided {
+			t.Fatalf("Expected 4 one-sided shapes with a straight of at least 4 cubes using %s but got %d", name, filtered.Len())
+		}
+
+		sorted := shapes.SortedBy(func(s *Shape) float64 { return -float64(s.LongestStraight()) })
+		if len(sorted) != shapes.Len() || sorted[0].LongestStraight() != 5 || sorted[len(sorted)-1].Size() != 1 {
+			t.Fatalf("Expected the straight shape with 5 cubes first and the single cube last using %s", name)
+		}
+
+		for _, s := range []Shapes{shapes, filtered} {
+			if c, ok := s.(io.Closer); ok {
+				c.Close()
+			}
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package shape
+
+// the point groups that are subgroups of the symmetry group of a cube, in Schoenflies notation ordered by the order of the
+// group
+var PointGroups = []string{
+	"C1",
+	"Ci", "Cs", "C2",
+	"C3",
+	"C2h", "D2", "C2v", "C4", "S4",
+	"S6", "D3", "C3v",
+	"D2h", "C4h", "D4", "C4v", "D2d",
+	"D3d", "T",
+	"D4h",
+	"Th", "Td", "O",
+	"Oh",
+}
+
+// Symmetry describes the rotations and reflections that map a shape onto itself
+type Symmetry struct {
+	Rotations     []Matrix // the rotations that map the shape onto itself
+	Isometries    []Matrix // the rotations and reflections that map the shape onto itself
+	RotationGroup string   // the point group of the rotations in Schoenflies notation, empty if the shape isn't 3D
+	PointGroup    string   // the point group of the rotations and reflections in Schoenflies notation, empty if the shape isn't 3D
+	dimensions    int
+}
+
+// Apply returns the shape transformed by m
+func (s *Shape) Apply(m Matrix) *Shape {
+	coords := make([]packedCoord, 0, len(s.coords))
+	for _, p := range s.coords {
+		coords = append(coords, pack(m.Apply(p.unpack())))
+	}
+
+	return s.withCoords(coords)
+}
+
+// Symmetry returns the rotations and reflections that map the shape onto itself and the point group they form
+func (s *Shape) Symmetry() Symmetry {
+	result := Symmetry{dimensions: s.Dimensions()}
+	result.Isometries = s.stabilizer(IsometriesWithDimensions(Free, result.dimensions))
+	for _, m := range result.Isometries {
+		if m.Determinant() == 1 {
+			result.Rotations = append(result.Rotations, m)
+		}
+	}
+	if result.dimensions == 3 {
+		result.RotationGroup = pointGroup(result.Rotations)
+		result.PointGroup = pointGroup(result.Isometries)
+	}
+
+	return result
+}
+
+// NumberOfOrientations returns the number of different orientations of the shape under the equivalence
+func (s Symmetry) NumberOfOrientations(equivalence Equivalence) int {
+	dimensions := s.dimensions
+	if dimensions == 0 {
+		dimensions = 3
+	}
+
+	switch equivalence {
+	case OneSided:
+		return len(IsometriesWithDimensions(OneSided, dimensions)) / len(s.Rotations)
+	case Free:
+		return len(IsometriesWithDimensions(Free, dimensions)) / len(s.Isometries)
+	}
+
+	return 1
+}
+
+// returns the Schoenflies notation of the group formed by the 3D matrices, the group is identified by its order and the
+// type of the elements it contains
+func pointGroup(group []Matrix) string {
+	var inversion bool
+	var mirrors, fourFold, improperFourFold int
+	for _, m := range group {
+		// the axes after the Z axis are not transformed and don't count
+		trace := m.Trace() - (MaxDimensions - 3)
+		if m.Determinant() == 1 {
+			if trace == 1 {
+				fourFold++
+			}
+			continue
+		}
+		switch trace {
+		case -3:
+			inversion = true
+		case 1:
+			mirrors++
+		case -1:
+			improperFourFold++
+		}
+	}
+
+	switch len(group) {
+	case 1:
+		return "C1"
+	case 2:
+		if inversion {
+			return "Ci"
+		}
+		if mirrors == 1 {
+			return "Cs"
+		}
+		return "C2"
+	case 3:
+		return "C3"
+	case 4:
+		if fourFold == 2 {
+			return "C4"
+		}
+		if improperFourFold == 2 {
+			return "S4"
+		}
+		if inversion {
+			return "C2h"
+		}
+		if mirrors == 2 {
+			return "C2v"
+		}
+		return "D2"
+	case 6:
+		if inversion {
+			return "S6"
+		}
+		if mirrors == 3 {
+			return "C3v"
+		}
+		return "D3"
+	case 8:
+		if inversion {
+			if fourFold == 2 {
+				return "C4h"
+			}
+			return "D2h"
+		}
+		if fourFold == 2 && mirrors == 4 {
+			return "C4v"
+		}
+		if fourFold == 2 {
+			return "D4"
+		}
+		return "D2d"
+	case 12:
+		if inversion {
+			return "D3d"
+		}
+		return "T"
+	case 16:
+		return "D4h"
+	case 24:
+		if inversion {
+			return "Th"
+		}
+		if mirrors > 0 {
+			return "Td"
+		}
+		return "O"
+	case 48:
+		return "Oh"
+	}
+
+	return "unknown"
+}
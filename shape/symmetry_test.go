@@ -0,0 +1,60 @@
+package shape_test
+
+import (
+	"testing"
+
+	. "github.com/munnik/cubes/shape"
+)
+
+func TestSymmetry(t *testing.T) {
+	var f func() Shapes
+	tests := []struct {
+		shape         *Shape
+		rotations     int
+		isometries    int
+		rotationGroup string
+		pointGroup    string
+	}{
+		{NewShape(f), 24, 48, "O", "Oh"},
+		{NewShape(f).MustAddCube(&Coord{1, 0, 0}), 8, 16, "D4", "D4h"},
+		{NewShape(f).MustAddCube(&Coord{1, 0, 0}).MustAddCube(&Coord{0, 1, 0}), 2, 4, "C2", "C2v"},
+		{NewShape(f).MustAddCube(&Coord{1, 0, 0}).MustAddCube(&Coord{0, 1, 0}).MustAddCube(&Coord{0, 0, 1}), 3, 6, "C3", "C3v"},
+		{NewShape(f).MustAddCube(&Coord{1, 0, 0}).MustAddCube(&Coord{1, 1, 0}).MustAddCube(&Coord{1, 1, 1}), 2, 2, "C2", "C2"},
+		{NewShape(f).MustAddCube(&Coord{1, 0, 0}).MustAddCube(&Coord{0, 1, 0}).MustAddCube(&Coord{1, 1, 0}), 8, 16, "D4", "D4h"},
+	}
+
+	for _, test := range tests {
+		symmetry := test.shape.Symmetry()
+		if len(symmetry.Rotations) != test.rotations || len(symmetry.Isometries) != test.isometries {
+			t.Fatalf("Expected %d rotations and %d isometries for %v but got %d and %d", test.rotations, test.isometries, test.shape, len(symmetry.Rotations), len(symmetry.Isometries))
+		}
+		if symmetry.RotationGroup != test.rotationGroup || symmetry.PointGroup != test.pointGroup {
+			t.Fatalf("Expected groups %s and %s for %v but got %s and %s", test.rotationGroup, test.pointGroup, test.shape, symmetry.RotationGroup, symmetry.PointGroup)
+		}
+	}
+}
+
+func TestPointGroupCounts(t *testing.T) {
+	// a shape is chiral if its point group only contains rotations, every chiral free shape is two one-sided shapes
+	chiralPointGroups := map[string]struct{}{"C1": {}, "C2": {}, "C3": {}, "C4": {}, "D2": {}, "D3": {}, "D4": {}, "T": {}, "O": {}}
+	size := ShapeSize(6)
+
+	counter := NewCounter(Free)
+	NewShape(NewShapesDefaultMap).KeepGrowingCanonical(Free, size, func(s *Shape) {
+		counter.Add(s)
+	})
+
+	total, chiral := 0, 0
+	for pointGroup, count := range counter.PointGroupCounts(size) {
+		total += count
+		if _, ok := chiralPointGroups[pointGroup]; ok {
+			chiral += count
+		}
+	}
+	if total != counter.Free(size) {
+		t.Fatalf("Expected the point groups to add up to %d but got %d", counter.Free(size), total)
+	}
+	if chiral != counter.OneSided(size)-counter.Free(size) {
+		t.Fatalf("Expected %d chiral shapes but got %d", counter.OneSided(size)-counter.Free(size), chiral)
+	}
+}
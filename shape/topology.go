@@ -0,0 +1,139 @@
+package shape
+
+import "fmt"
+
+// The topology of a shape is the topology of the union of its closed cubes. Cubes that share only an edge or a vertex
+// are connected through that edge or vertex, so the union is connected for every adjacency.
+
+// EulerCharacteristic returns the alternating sum of the number of vertices, edges, faces, cubes and so on of the union
+// of the cubes, for example 1 for a single cube, 0 for a ring of cubes and 2 for a shell of cubes around a cavity
+func (s *Shape) EulerCharacteristic() int {
+	dimensions := s.Dimensions()
+
+	// a face of a cube at c is stored as 2*c+v with v 0, 1 or 2 for every axis, the number of axes with v 1 is the
+	// dimension of the face, faces shared by several cubes are stored once
+	faces := make(map[Coord]struct{})
+	for _, c := range s.Coords() {
+		var v Coord
+		for {
+			var face Coord
+			for axis := 0; axis < dimensions; axis++ {
+				face[axis] = 2*c[axis] + v[axis]
+			}
+			faces[face] = struct{}{}
+
+			axis := 0
+			for ; axis < dimensions && v[axis] == 2; axis++ {
+				v[axis] = 0
+			}
+			if axis == dimensions {
+				break
+			}
+			v[axis]++
+		}
+	}
+
+	result := 0
+	for face := range faces {
+		dimension := 0
+		for axis := 0; axis < dimensions; axis++ {
+			dimension += face[axis] & 1
+		}
+		if dimension%2 == 0 {
+			result++
+		} else {
+			result--
+		}
+	}
+
+	return result
+}
+
+// Cavities returns the number of fully enclosed empty regions of the shape, empty cells that share a face belong to the
+// same region. Empty cells that only share an edge or a vertex are separated by the cubes around that edge or vertex.
+func (s *Shape) Cavities() int {
+	dimensions := s.Dimensions()
+	box := s.BoundingBox()
+
+	// the empty cells are searched in the bounding box with a border of one cell, the border is outside the shape
+	var min, extents Coord
+	volume := 1
+	for axis := 0; axis < dimensions; axis++ {
+		min[axis] = box.Min[axis] - 1
+		extents[axis] = box.Max[axis] - box.Min[axis] + 3
+		volume *= extents[axis]
+	}
+	index := func(c Coord) int {
+		result := 0
+		for axis := dimensions - 1; axis >= 0; axis-- {
+			result = result*extents[axis] + c[axis] - min[axis]
+		}
+		return result
+	}
+	coord := func(i int) Coord {
+		var result Coord
+		for axis := 0; axis < dimensions; axis++ {
+			result[axis] = i%extents[axis] + min[axis]
+			i /= extents[axis]
+		}
+		return result
+	}
+
+	visited := make([]bool, volume)
+	for _, c := range s.Coords() {
+		visited[index(c)] = true
+	}
+
+	result := 0
+	queue := make([]int, 0)
+	for start := range visited {
+		if visited[start] {
+			continue
+		}
+		enclosed := true
+		visited[start] = true
+		queue = append(queue[:0], start)
+		for len(queue) > 0 {
+			c := coord(queue[0])
+			queue = queue[1:]
+			for axis := 0; axis < dimensions; axis++ {
+				for _, delta := range [...]int{-1, 1} {
+					neighbor := c
+					neighbor[axis] += delta
+					if neighbor[axis] < min[axis] || neighbor[axis] >= min[axis]+extents[axis] {
+						// c is in the border
+						enclosed = false
+						continue
+					}
+					if i := index(neighbor); !visited[i] {
+						visited[i] = true
+						queue = append(queue, i)
+					}
+				}
+			}
+		}
+		if enclosed {
+			result++
+		}
+	}
+
+	return result
+}
+
+// Tunnels returns the number of tunnels of the shape, the first Betti number, which is the genus of the surface of a
+// shape without cavities. In 2 dimensions every hole is a tunnel and a cavity. The tunnels follow from the Euler
+// characteristic and the cavities for up to 3 dimensions, Tunnels panics for shapes with more dimensions.
+func (s *Shape) Tunnels() int {
+	switch s.Dimensions() {
+	case 1:
+		return 0
+	case 2:
+		// χ = b0 - b1
+		return 1 - s.EulerCharacteristic()
+	case 3:
+		// χ = b0 - b1 + b2
+		return 1 + s.Cavities() - s.EulerCharacteristic()
+	}
+
+	panic(fmt.Sprintf("tunnels can't be determined for shapes with %d dimensions", s.Dimensions()))
+}
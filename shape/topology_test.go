@@ -0,0 +1,87 @@
+package shape_test
+
+import (
+	"testing"
+
+	. "github.com/munnik/cubes/shape"
+)
+
+// returns the shape with the cubes at coords, the first coord should be the origin
+func shapeWithCoords(dimensions int, adjacency Adjacency, coords ...Coord) *Shape {
+	var f func() Shapes
+	result := NewShapeWithDimensions(f, dimensions)
+	result.SetAdjacency(adjacency)
+	for i := 1; i < len(coords); i++ {
+		result = result.MustAddCube(&coords[i])
+	}
+
+	return result
+}
+
+func TestTopology(t *testing.T) {
+	ring := []Coord{{0, 0, 0}, {1, 0, 0}, {2, 0, 0}, {2, 1, 0}, {2, 2, 0}, {1, 2, 0}, {0, 2, 0}, {0, 1, 0}}
+	shell := []Coord{{0, 0, 0}}
+	for x := 0; x < 3; x++ {
+		for y := 0; y < 3; y++ {
+			for z := 0; z < 3; z++ {
+				if (x != 0 || y != 0 || z != 0) && (x != 1 || y != 1 || z != 1) {
+					shell = append(shell, Coord{x, y, z})
+				}
+			}
+		}
+	}
+
+	for _, test := range []struct {
+		name     string
+		shape    *Shape
+		euler    int
+		cavities int
+		tunnels  int
+	}{
+		{"cube", shapeWithCoords(3, Face, Coord{}), 1, 0, 0},
+		{"ring", shapeWithCoords(3, Face, ring...), 0, 0, 1},
+		{"shell", shapeWithCoords(3, Face, shell...), 2, 1, 0},
+		{"edge", shapeWithCoords(3, Edge, Coord{}, Coord{1, 1, 0}), 1, 0, 0},
+		{"square ring", shapeWithCoords(2, Face, ring...), 0, 1, 1},
+		{"diamond", shapeWithCoords(2, Vertex, Coord{}, Coord{1, 1, 0}, Coord{2, 0, 0}, Coord{1, -1, 0}), 0, 1, 1},
+		{"line", shapeWithCoords(1, Face, Coord{}, Coord{1, 0, 0}, Coord{2, 0, 0}), 1, 0, 0},
+	} {
+		if euler := test.shape.EulerCharacteristic(); euler != test.euler {
+			t.Fatalf("Expected Euler characteristic %d for the %s but got %d", test.euler, test.name, euler)
+		}
+		if cavities := test.shape.Cavities(); cavities != test.cavities {
+			t.Fatalf("Expected %d cavities for the %s but got %d", test.cavities, test.name, cavities)
+		}
+		if tunnels := test.shape.Tunnels(); tunnels != test.tunnels {
+			t.Fatalf("Expected %d tunnels for the %s but got %d", test.tunnels, test.name, tunnels)
+		}
+	}
+
+	tesseract := shapeWithCoords(4, Face, Coord{}, Coord{0, 0, 0, 1})
+	if euler := tesseract.EulerCharacteristic(); euler != 1 {
+		t.Fatalf("Expected Euler characteristic 1 for %v but got %d", tesseract, euler)
+	}
+}
+
+func TestTopologySmallShapes(t *testing.T) {
+	// the ends of a chain of 7 cubes can share an edge, which closes the chain into a ring, there are no polycubes with a
+	// cavity with less than 18 cubes
+	tunnels := 0
+	NewShape(NewShapesDefaultMap).KeepGrowingCanonical(Free, 8, func(s *Shape) {
+		if s.Cavities() != 0 {
+			t.Fatalf("Expected no cavities for %v", s)
+		}
+		if s.EulerCharacteristic() != 1-s.Tunnels() {
+			t.Fatalf("Expected Euler characteristic %d for %v but got %d", 1-s.Tunnels(), s, s.EulerCharacteristic())
+		}
+		if s.Tunnels() > 0 {
+			if s.Size() < 7 {
+				t.Fatalf("Expected no tunnels for %v", s)
+			}
+			tunnels++
+		}
+	})
+	if tunnels == 0 {
+		t.Fatalf("Expected polycubes with a tunnel")
+	}
+}
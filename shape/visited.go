@@ -0,0 +1,94 @@
+package shape
+
+import (
+	"container/list"
+	"sync"
+)
+
+// DefaultVisitedCapacity is the number of shapes Visited remembers by default, a bounded number so remembering the
+// shapes doesn't use more memory than the shapes that are grown
+const DefaultVisitedCapacity = 1 << 20
+
+// Visited is the set of canonical shapes that are already expanded, it is safe for concurrent use. When the capacity is
+// bigger than 0 only the most recently visited shapes are remembered, shapes that are forgotten are expanded again
+// when they are visited again.
+type Visited struct {
+	mu        sync.Mutex
+	capacity  int
+	entries   map[Score]*list.Element // the elements are nil when the capacity is 0
+	order     *list.List              // the most recently visited score first
+	hits      int64
+	misses    int64
+	evictions int64
+}
+
+// NewVisited returns an empty set that remembers at most capacity shapes, 0 means no limit
+func NewVisited(capacity int) *Visited {
+	return &Visited{
+		capacity: capacity,
+		entries:  make(map[Score]*list.Element),
+		order:    list.New(),
+	}
+}
+
+// Visit marks the score as visited, returns false if the score was already visited
+func (v *Visited) Visit(score Score) bool {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if element, ok := v.entries[score]; ok {
+		if element != nil {
+			v.order.MoveToFront(element)
+		}
+		v.hits++
+		return false
+	}
+	v.misses++
+
+	if v.capacity <= 0 {
+		v.entries[score] = nil
+		return true
+	}
+	v.entries[score] = v.order.PushFront(score)
+	if v.order.Len() > v.capacity {
+		oldest := v.order.Back()
+		v.order.Remove(oldest)
+		delete(v.entries, oldest.Value.(Score))
+		v.evictions++
+	}
+
+	return true
+}
+
+// Len returns the number of shapes that are remembered
+func (v *Visited) Len() int {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	return len(v.entries)
+}
+
+// Hits returns the number of times a shape was visited that was already visited, every hit is a subtree that isn't
+// expanded again
+func (v *Visited) Hits() int64 {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	return v.hits
+}
+
+// Misses returns the number of times a shape was visited for the first time or after it was forgotten
+func (v *Visited) Misses() int64 {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	return v.misses
+}
+
+// Evictions returns the number of shapes that are forgotten because the capacity was reached
+func (v *Visited) Evictions() int64 {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	return v.evictions
+}
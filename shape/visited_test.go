@@ -0,0 +1,63 @@
+package shape_test
+
+import (
+	"testing"
+
+	. "github.com/munnik/cubes/shape"
+)
+
+func TestVisited(t *testing.T) {
+	visited := NewVisited(0)
+	for i, expected := range []bool{true, true, false, true, false} {
+		score := Score([]string{"a", "b", "a", "c", "b"}[i])
+		if visited.Visit(score) != expected {
+			t.Fatalf("Expected visiting %q to return %t", score, expected)
+		}
+	}
+	if visited.Len() != 3 || visited.Hits() != 2 || visited.Misses() != 3 || visited.Evictions() != 0 {
+		t.Fatalf("Expected 3 shapes, 2 hits, 3 misses and 0 evictions but got %d, %d, %d and %d", visited.Len(), visited.Hits(), visited.Misses(), visited.Evictions())
+	}
+}
+
+func TestVisitedLRU(t *testing.T) {
+	visited := NewVisited(2)
+	visited.Visit("a")
+	visited.Visit("b")
+	visited.Visit("a") // b is now the least recently used
+	visited.Visit("c")
+
+	if visited.Len() != 2 || visited.Evictions() != 1 {
+		t.Fatalf("Expected 2 shapes and 1 eviction but got %d and %d", visited.Len(), visited.Evictions())
+	}
+	if visited.Visit("a") {
+		t.Fatalf("Expected a to be remembered")
+	}
+	if !visited.Visit("b") {
+		t.Fatalf("Expected b to be forgotten")
+	}
+}
+
+func TestPoolKeepGrowingVisited(t *testing.T) {
+	expected := []int{1, 1, 2, 8, 29, 166}
+
+	for _, capacity := range []int{0, 10} {
+		for _, workers := range []int{1, 4} {
+			visited := NewVisited(capacity)
+			pool := NewPool(workers)
+			pool.SetVisited(visited)
+			shapes := pool.KeepGrowing([]*Shape{NewShape(NewShapesDefaultMap)}, ShapeSize(len(expected)))
+			for i := range expected {
+				if len(shapes.GetAllWithSize(ShapeSize(i+1))) != expected[i] {
+					t.Fatalf("Expected %d shapes with size %d using %d workers and capacity %d but got %d", expected[i], i+1, workers, capacity, len(shapes.GetAllWithSize(ShapeSize(i+1))))
+				}
+			}
+			if visited.Hits() == 0 {
+				t.Fatalf("Expected shapes to be skipped using %d workers and capacity %d", workers, capacity)
+			}
+			// only the shapes smaller than the maximum size are expanded, every one of them once without a limit
+			if capacity == 0 && (visited.Misses() != 41 || visited.Len() != 41) {
+				t.Fatalf("Expected 41 shapes to be grown using %d workers but got %d misses and %d shapes", workers, visited.Misses(), visited.Len())
+			}
+		}
+	}
+}
@@ -0,0 +1,300 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	. "github.com/munnik/cubes/shape"
+	"github.com/munnik/cubes/store"
+)
+
+// the parents are grown until there are at least this many parents per shard, so the work is evenly spread over the
+// shards
+const shardSplitFactor = 16
+
+// the parents are grown until there are at least this many roots, every root is grown by one worker so it is known when
+// all the shapes of a root are found. The number doesn't depend on the number of workers so a checkpoint can be resumed
+// with another number of workers.
+const rootTarget = 4096
+
+// shardFileName returns the name of the file a shard writes its shapes to
+func shardFileName(fileName string, shard int, shards int) string {
+	if shards <= 1 || fileName == "" {
+		return fileName
+	}
+	// the extension stays at the end so the format of the file is detected from the extension
+	if extension := store.Extension(fileName); extension != "" {
+		return fmt.Sprintf("%s.%d-of-%d%s", strings.TrimSuffix(fileName, extension), shard, shards, extension)
+	}
+
+	return fmt.Sprintf("%s.%d-of-%d", fileName, shard, shards)
+}
+
+func shardHeaders(shard int, shards int) []store.Header {
+	if shards <= 1 {
+		return nil
+	}
+
+	return []store.Header{{Key: store.SHARD_HEADER, Value: fmt.Sprintf("%d/%d", shard, shards)}}
+}
+
+// splitShards returns the shapes that are found by this shard without growing and the parents this shard grows new
+// shapes from. Every shard grows the parents in shapes the same way until there are enough parents to spread over the
+// shards, the parents are spread over the shards using the shard of their score. The shapes in shapes and the shapes
+// that are grown before the parents are spread belong to the first shard, the first shard adds its found shapes to
+// shapes and returns them, the other shards close shapes and return new shapes.
+func splitShards(pool *Pool, shapes Shapes, newShapes func() Shapes, maxSize ShapeSize, shard int, shards int) (Shapes, []*Shape) {
+	level := parents(shapes, newShapes)
+	found := shapes
+	if shard != 0 {
+		found = newShapes()
+		CloseShapes(shapes)
+	}
+	if shards <= 1 {
+		return found, level
+	}
+
+	grown := false
+	for len(level) < shardSplitFactor*shards && level[0].Size() < maxSize {
+		if grown && shard == 0 {
+			addShapes(found, level)
+		}
+		level = nextLevel(pool, level, found.Equivalence())
+		grown = true
+	}
+
+	result := make([]*Shape, 0, len(level)/shards+1)
+	for _, shape := range level {
+		if shape.Score().Shard(shards) != shard {
+			continue
+		}
+		result = append(result, shape)
+		if grown {
+			found.Add(*shape)
+		}
+	}
+
+	return found, result
+}
+
+// splitRoots grows the parents level by level until there are at least rootTarget roots to divide the work over the
+// workers of the pool, every root can then be grown by a single worker. The shapes of the levels before the roots are
+// added to found.
+func splitRoots(pool *Pool, equivalence Equivalence, found Shapes, parents []*Shape, maxSize ShapeSize) []*Shape {
+	roots := parents
+	for len(roots) > 0 && len(roots) < rootTarget && roots[0].Size() < maxSize {
+		if roots[0].Size() > parents[0].Size() {
+			addShapes(found, roots)
+		}
+		roots = nextLevel(pool, roots, equivalence)
+	}
+	if len(roots) > 0 && roots[0].Size() > parents[0].Size() {
+		addShapes(found, roots)
+	}
+
+	return roots
+}
+
+// adds the level to the shapes
+func addShapes(shapes Shapes, level []*Shape) {
+	for _, shape := range level {
+		shapes.Add(*shape)
+	}
+}
+
+// nextLevel returns all the unique shapes that are grown from the level by adding one cube
+func nextLevel(pool *Pool, level []*Shape, equivalence Equivalence) []*Shape {
+	children := make([][]*Shape, pool.Workers())
+	pool.KeepGrowingCanonical(level, equivalence, level[0].Size()+1, func(worker int, s *Shape) {
+		children[worker] = append(children[worker], s)
+	})
+
+	result := make([]*Shape, 0)
+	for _, c := range children {
+		result = append(result, c...)
+	}
+
+	return result
+}
+
+// merge combines the shapes in the files written by the shards into one file without duplicates, and reports which
+// shards are merged and how many shapes are found. The files are read one shape at a time and the shapes are merged
+// with the Disk method, so merging doesn't need more memory than a single shard.
+func merge(args []string) {
+	var fileName string
+	var equivalenceName string
+	var memoryBudget int
+	flags := flag.NewFlagSet("merge", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s merge [flags] shard files...\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	flags.StringVar(&fileName, "f", "", "File name the merged polycubes are written to. If no file name is specified only the report is printed.")
+	flags.StringVar(&equivalenceName, "e", "", "Equivalence that was used to create the shard files. Options are one-sided, free and fixed. If not specified the equivalence recorded in the first shard file is used.")
+	flags.IntVar(&memoryBudget, "memory-budget", DefaultDiskBudget>>20, "Number of MiB of the merged shapes that are kept in memory to sort them before they are written to temporary files, the files are written to the directory in TMPDIR.")
+	flags.Parse(args)
+
+	equivalence := OneSided
+	var err error
+	if equivalenceName != "" {
+		equivalence, err = EquivalenceFromString(equivalenceName)
+	} else if flags.NArg() > 0 {
+		equivalence, err = fileEquivalence(flags.Arg(0))
+	}
+	if err != nil {
+		panic(err)
+	}
+	merged := NewShapesWithEquivalence(NewShapesDiskWithBudget(int64(memoryBudget)<<20), equivalence)()
+	var headers []store.Header
+	total := 0
+	shards := make(map[int]string)
+	numberOfShards := 0
+	dimensions := 3
+	adjacency := Face
+	valid := true
+	fmt.Printf("%-8s %-40s %12s\n", "shard", "file", "shapes")
+	for i, path := range flags.Args() {
+		fileHeaders, err := store.ReadHeaders(path, "")
+		if err != nil {
+			panic(err)
+		}
+		e, err := store.HeaderEquivalence(fileHeaders)
+		if err != nil {
+			panic(err)
+		}
+		if e != equivalence {
+			fmt.Printf("%s contains %v shapes but %v shapes are merged\n", path, e, equivalence)
+			valid = false
+			continue
+		}
+		if headers == nil {
+			headers = mergedHeaders(fileHeaders)
+		}
+		shard, of := "", 0
+		for _, header := range fileHeaders {
+			if header.Key == store.SHARD_HEADER {
+				shard = header.Value
+			}
+		}
+		d, err := store.Dimensions(fileHeaders)
+		if err != nil {
+			panic(err)
+		}
+		if i > 0 && d != dimensions {
+			fmt.Printf("%s contains shapes with %d dimensions but other files with %d dimensions\n", path, d, dimensions)
+			valid = false
+		}
+		dimensions = d
+		a, err := store.HeaderAdjacency(fileHeaders)
+		if err != nil {
+			panic(err)
+		}
+		if i > 0 && a != adjacency {
+			fmt.Printf("%s contains shapes with %v adjacency but other files with %v adjacency\n", path, a, adjacency)
+			valid = false
+		}
+		adjacency = a
+		if index, n, ok := parseShard(shard); ok {
+			if numberOfShards != 0 && n != numberOfShards {
+				fmt.Printf("%s was created by shard %s but other files by %d shards\n", path, shard, numberOfShards)
+				valid = false
+			}
+			if other, ok := shards[index]; ok {
+				fmt.Printf("%s and %s were both created by shard %d\n", other, path, index)
+				valid = false
+			}
+			shards[index] = path
+			numberOfShards, of = n, n
+		}
+
+		count := 0
+		if err := store.ScanShapes(path, "", func(s *Shape) error {
+			merged.Add(*s.Canonical(equivalence))
+			count++
+			return nil
+		}); err != nil {
+			panic(err)
+		}
+		if of == 0 {
+			shard = "-"
+		}
+		fmt.Printf("%-8s %-40s %12d\n", shard, path, count)
+		total += count
+	}
+
+	missing := make([]string, 0)
+	for i := 0; i < numberOfShards; i++ {
+		if _, ok := shards[i]; !ok {
+			missing = append(missing, strconv.Itoa(i))
+		}
+	}
+	if len(missing) > 0 {
+		fmt.Printf("missing shards: %s of %d\n", strings.Join(missing, ", "), numberOfShards)
+		valid = false
+	}
+
+	fmt.Printf("duplicates removed: %d\n", total-merged.Len())
+	fmt.Printf("%4s %20s\n", "n", equivalence)
+	for size := ShapeSize(1); size <= merged.MaxSize(); size++ {
+		count := CountWithSize(merged, size)
+		fmt.Printf("%4d %20d\n", size, count)
+		if count == 0 {
+			valid = false
+		}
+	}
+
+	if fileName != "" {
+		store.WriteShapes(merged, fileName, "", headers...)
+	}
+	CloseShapes(merged)
+
+	if !valid {
+		fmt.Println("verification failed")
+		os.Exit(1)
+	}
+	fmt.Println("verification passed")
+}
+
+// returns the headers of a shard file that are written to the merged file, like the headers of a file that is written
+// by a single run
+func mergedHeaders(headers []store.Header) []store.Header {
+	result := make([]store.Header, 0, len(headers))
+	for _, header := range copiedHeaders(headers) {
+		if header.Key != store.SHARD_HEADER && header.Key != store.COUNT_HEADER {
+			result = append(result, header)
+		}
+	}
+
+	return result
+}
+
+// returns the equivalence recorded in the headers of the file
+func fileEquivalence(path string) (Equivalence, error) {
+	headers, err := store.ReadHeaders(path, "")
+	if err != nil {
+		return 0, err
+	}
+
+	return store.HeaderEquivalence(headers)
+}
+
+// parses a shard header in the form index/shards
+func parseShard(s string) (int, int, bool) {
+	index, shards, ok := strings.Cut(s, "/")
+	if !ok {
+		return 0, 0, false
+	}
+	i, err := strconv.Atoi(index)
+	if err != nil {
+		return 0, 0, false
+	}
+	n, err := strconv.Atoi(shards)
+	if err != nil || i < 0 || i >= n {
+		return 0, 0, false
+	}
+
+	return i, n, true
+}
@@ -0,0 +1,530 @@
+package store
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"os"
+
+	. "github.com/munnik/cubes/shape"
+)
+
+// BINARY_MAGIC are the first bytes of a binary file
+const BINARY_MAGIC = "CUBESBIN"
+
+// BINARY_FORMAT_VERSION is the version of the binary format that is written
+const BINARY_FORMAT_VERSION = 1
+
+// The binary format stores the score of every shape, which packs the coordinates of the cubes after translating the
+// shape to positive coordinates. The scores of all shapes with the same size have the same length, so the k-th shape
+// with a size is read without reading the shapes before it. All numbers are stored big endian.
+//
+//	magic          8 bytes, CUBESBIN
+//	format         uint32
+//	headers        uint32 number of headers, every header is a uint16 length and the key followed by a uint16 length
+//	               and the value, the headers are the same as the headers of a text file
+//	max size       uint32
+//	index          for every size from 1 up to and including the max size the uint64 offset of the first shape, the
+//	               uint64 number of shapes and the uint32 CRC-32 checksum of the scores
+//	shapes         the scores of the shapes, ordered by size and score
+
+// the number of bytes of an entry of the index
+const binaryIndexEntryLength = 8 + 8 + 4
+
+// binaryIndexEntry is the position of the shapes with a size in a binary file
+type binaryIndexEntry struct {
+	Offset   uint64
+	Count    uint64
+	Checksum uint32
+}
+
+// WriteBinary writes the shapes ordered by size and score to a binary file, the headers should record the dimensions
+// and adjacency of the shapes like the headers of a text file
+func WriteBinary(s Shapes, path string, headers ...Header) {
+	f, err := os.Create(path)
+	if err != nil {
+		panic(err)
+	}
+	if err := writeBinary(f, s, headers); err != nil {
+		f.Close()
+		panic(err)
+	}
+	if err := f.Close(); err != nil {
+		panic(err)
+	}
+}
+
+func writeBinary(f *os.File, s Shapes, headers []Header) error {
+	headers = append([]Header{
+		{Key: VERSION_HEADER, Value: toolVersion()},
+		{Key: EQUIVALENCE_HEADER, Value: s.Equivalence().String()},
+	}, headers...)
+	properties, err := readShapeProperties(headers)
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(f)
+	if err := writeBinaryHeaders(w, headers); err != nil {
+		return err
+	}
+	if err := binary.Write(w, binary.BigEndian, uint32(s.MaxSize())); err != nil {
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	// the index is written when the shapes are written and the counts and checksums are known
+	indexOffset, err := f.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	index := make([]binaryIndexEntry, s.MaxSize())
+	offset := uint64(indexOffset) + uint64(len(index))*binaryIndexEntryLength
+	if _, err := f.Seek(int64(offset), io.SeekStart); err != nil {
+		return err
+	}
+	w.Reset(f)
+	for size := ShapeSize(1); size <= s.MaxSize(); size++ {
+		entry := &index[size-1]
+		entry.Offset = offset
+		checksum := crc32.NewIEEE()
+		length := ScoreLength(size, properties.dimensions)
+		s.Iterate(size, func(shape *Shape) bool {
+			score := shape.Score()
+			if len(score) != length {
+				err = fmt.Errorf("shape %v has %d dimensions but the file has shapes with %d dimensions", shape, shape.Dimensions(), properties.dimensions)
+				return false
+			}
+			checksum.Write([]byte(score))
+			if _, err = w.WriteString(string(score)); err != nil {
+				return false
+			}
+			entry.Count++
+			return true
+		})
+		if err != nil {
+			return err
+		}
+		entry.Checksum = checksum.Sum32()
+		offset += entry.Count * uint64(length)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if _, err := f.Seek(indexOffset, io.SeekStart); err != nil {
+		return err
+	}
+	w.Reset(f)
+	if err := binary.Write(w, binary.BigEndian, index); err != nil {
+		return err
+	}
+
+	return w.Flush()
+}
+
+func writeBinaryHeaders(w io.Writer, headers []Header) error {
+	if _, err := io.WriteString(w, BINARY_MAGIC); err != nil {
+		return err
+	}
+	if err := binary.Write(w, binary.BigEndian, uint32(BINARY_FORMAT_VERSION)); err != nil {
+		return err
+	}
+	if err := binary.Write(w, binary.BigEndian, uint32(len(headers))); err != nil {
+		return err
+	}
+	for _, header := range headers {
+		for _, s := range []string{header.Key, header.Value} {
+			if len(s) > 0xffff {
+				return fmt.Errorf("header %s is too long", header.Key)
+			}
+			if err := binary.Write(w, binary.BigEndian, uint16(len(s))); err != nil {
+				return err
+			}
+			if _, err := io.WriteString(w, s); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// BinaryFile is an open binary file, the shapes are read from the file when they are requested
+type BinaryFile struct {
+	f           *os.File
+	path        string
+	headers     []Header
+	equivalence Equivalence
+	properties  *shapeProperties
+	index       []binaryIndexEntry
+}
+
+// OpenBinary opens the binary file and reads the headers and the index
+func OpenBinary(path string) (*BinaryFile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	result := &BinaryFile{f: f, path: path}
+	if err := result.readHeaders(&io.LimitedReader{R: bufio.NewReader(f), N: info.Size()}); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return result, nil
+}
+
+// reads the headers and the index, the lengths in the file are checked against the remaining bytes of r before anything
+// is allocated for them
+func (b *BinaryFile) readHeaders(r *io.LimitedReader) error {
+	magic := make([]byte, len(BINARY_MAGIC))
+	if _, err := io.ReadFull(r, magic); err != nil {
+		return readError(err)
+	}
+	if string(magic) != BINARY_MAGIC {
+		return errors.New("file is not a binary file")
+	}
+	var format, headers uint32
+	if err := binary.Read(r, binary.BigEndian, &format); err != nil {
+		return readError(err)
+	}
+	if format > BINARY_FORMAT_VERSION {
+		return fmt.Errorf("file has format %d but at most format %d is supported", format, BINARY_FORMAT_VERSION)
+	}
+	if err := binary.Read(r, binary.BigEndian, &headers); err != nil {
+		return readError(err)
+	}
+	// every header has at least the lengths of its key and value
+	if int64(headers)*4 > r.N {
+		return readError(io.ErrUnexpectedEOF)
+	}
+
+	b.headers = make([]Header, 0, headers)
+	for i := uint32(0); i < headers; i++ {
+		var header [2]string
+		for j := range header {
+			var length uint16
+			if err := binary.Read(r, binary.BigEndian, &length); err != nil {
+				return readError(err)
+			}
+			s := make([]byte, length)
+			if _, err := io.ReadFull(r, s); err != nil {
+				return readError(err)
+			}
+			header[j] = string(s)
+		}
+		b.headers = append(b.headers, Header{Key: header[0], Value: header[1]})
+	}
+	equivalence, err := HeaderEquivalence(b.headers)
+	if err != nil {
+		return err
+	}
+	b.equivalence = equivalence
+	properties, err := readShapeProperties(b.headers)
+	if err != nil {
+		return err
+	}
+	b.properties = properties
+
+	var maxSize uint32
+	if err := binary.Read(r, binary.BigEndian, &maxSize); err != nil {
+		return readError(err)
+	}
+	if int64(maxSize)*int64(binary.Size(binaryIndexEntry{})) > r.N {
+		return readError(io.ErrUnexpectedEOF)
+	}
+	b.index = make([]binaryIndexEntry, maxSize)
+	if err := binary.Read(r, binary.BigEndian, b.index); err != nil {
+		return readError(err)
+	}
+
+	return nil
+}
+
+// returns an error that tells the file ends too early if err is caused by the end of the file
+func readError(err error) error {
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return errors.New("file ends before the end of the headers, writing the file was interrupted")
+	}
+
+	return err
+}
+
+// Headers returns the headers of the file
+func (b *BinaryFile) Headers() []Header {
+	return b.headers
+}
+
+// Equivalence returns the equivalence the shapes in the file are unique under
+func (b *BinaryFile) Equivalence() Equivalence {
+	return b.equivalence
+}
+
+func (b *BinaryFile) MaxSize() ShapeSize {
+	return ShapeSize(len(b.index))
+}
+
+// Count returns the number of shapes with the size in the file
+func (b *BinaryFile) Count(size ShapeSize) int {
+	if size < 1 || size > b.MaxSize() {
+		return 0
+	}
+
+	return int(b.index[size-1].Count)
+}
+
+// Shape returns the k-th shape with the size, counting from 0, the shape is read without reading the shapes before it
+func (b *BinaryFile) Shape(size ShapeSize, k int) (*Shape, error) {
+	if k < 0 || k >= b.Count(size) {
+		return nil, fmt.Errorf("%s: file contains %d shapes with size %d, shape %d doesn't exist", b.path, b.Count(size), size, k)
+	}
+
+	length := ScoreLength(size, b.properties.dimensions)
+	score := make([]byte, length)
+	if _, err := b.f.ReadAt(score, int64(b.index[size-1].Offset)+int64(k*length)); err != nil {
+		return nil, fmt.Errorf("%s: can't read shape %d with size %d: %w", b.path, k, size, err)
+	}
+
+	return b.shape(score, size, k)
+}
+
+// Scan calls fn for every shape with the size in the order of the file, the checksum of the shapes is checked when all
+// shapes are read
+func (b *BinaryFile) Scan(size ShapeSize, fn func(*Shape) error) error {
+	scanner := b.scanner(size)
+	for {
+		shape, err := scanner.next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := fn(shape); err != nil {
+			return err
+		}
+	}
+}
+
+// binaryScanner reads the shapes with a size in the order of the file
+type binaryScanner struct {
+	b        *BinaryFile
+	size     ShapeSize
+	count    int
+	k        int // the number of shapes that are read
+	length   int
+	r        *bufio.Reader
+	checksum hash.Hash32
+}
+
+func (b *BinaryFile) scanner(size ShapeSize) *binaryScanner {
+	result := &binaryScanner{
+		b:        b,
+		size:     size,
+		count:    b.Count(size),
+		length:   ScoreLength(size, b.properties.dimensions),
+		checksum: crc32.NewIEEE(),
+	}
+	if result.count > 0 {
+		entry := b.index[size-1]
+		result.r = bufio.NewReader(io.NewSectionReader(b.f, int64(entry.Offset), int64(result.count*result.length)))
+	}
+
+	return result
+}
+
+// returns the next shape, io.EOF is returned after the last shape when the checksum of the shapes matches the index
+func (s *binaryScanner) next() (*Shape, error) {
+	if s.k == s.count {
+		if s.count > 0 && s.checksum.Sum32() != s.b.index[s.size-1].Checksum {
+			return nil, fmt.Errorf("%s: checksum of the shapes with size %d is %08x but the file records %08x", s.b.path, s.size, s.checksum.Sum32(), s.b.index[s.size-1].Checksum)
+		}
+		return nil, io.EOF
+	}
+
+	score := make([]byte, s.length)
+	if _, err := io.ReadFull(s.r, score); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil, fmt.Errorf("%s: file ends at shape %d with size %d but contains %d shapes with size %d", s.b.path, s.k, s.size, s.count, s.size)
+		}
+		return nil, err
+	}
+	s.checksum.Write(score)
+	s.k++
+
+	return s.b.shape(score, s.size, s.k-1)
+}
+
+// returns the shape with the score that is read from the file
+func (b *BinaryFile) shape(score []byte, size ShapeSize, k int) (*Shape, error) {
+	shape, err := ShapeFromScore(Score(score), b.properties.dimensions)
+	if err != nil {
+		return nil, fmt.Errorf("%s: shape %d with size %d: %w", b.path, k, size, err)
+	}
+	b.properties.set(shape)
+	if err := shape.Validate(); err != nil {
+		return nil, fmt.Errorf("%s: shape %d with size %d: %w", b.path, k, size, err)
+	}
+
+	return shape, nil
+}
+
+func (b *BinaryFile) Close() error {
+	return b.f.Close()
+}
+
+// ReadBinary adds the shapes in the binary file to shapes, an error is returned if the file records a different
+// equivalence than the equivalence of shapes
+func ReadBinary(path string, shapes Shapes) (Shapes, error) {
+	b, err := OpenBinary(path)
+	if err != nil {
+		return nil, err
+	}
+	defer b.Close()
+
+	if b.Equivalence() != shapes.Equivalence() {
+		return nil, fmt.Errorf("%s: file contains %v shapes but %v shapes are requested", path, b.Equivalence(), shapes.Equivalence())
+	}
+	for size := ShapeSize(1); size <= b.MaxSize(); size++ {
+		if err := b.Scan(size, func(s *Shape) error {
+			shapes.Add(*s)
+			return nil
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	return shapes, nil
+}
+
+const BINARY_FORMAT = "binary"
+
+// BinaryMemoryBudget is the number of bytes of shapes a binary store keeps in memory to sort them before they are
+// written to temporary files in the directory in TMPDIR
+var BinaryMemoryBudget int64 = DefaultDiskBudget
+
+func init() {
+	RegisterFormat(Format{Name: BINARY_FORMAT, Extensions: []string{".bin"}, Magic: BINARY_MAGIC, New: func() Store { return &binaryStore{} }})
+}
+
+// binaryStore reads binary files one size at a time and writes them when the store is closed, because the shapes are
+// ordered by size and score. The shapes that are written are sorted with ShapesDisk. Binary files are always read
+// completely and shapes can't be added to them.
+type binaryStore struct {
+	b       *BinaryFile
+	scanner *binaryScanner
+	f       *os.File
+	shapes  *ShapesDisk
+	headers []Header
+}
+
+func (s *binaryStore) Open(path string, complete bool) error {
+	var err error
+	if s.b, err = OpenBinary(path); err != nil {
+		return err
+	}
+	s.scanner = s.b.scanner(1)
+
+	return nil
+}
+
+// Headers returns the headers of the file followed by the number of shapes of every size that are recorded in the index
+func (s *binaryStore) Headers() []Header {
+	if s.b == nil {
+		return nil
+	}
+
+	counts := make(map[ShapeSize]int)
+	for size := ShapeSize(1); size <= s.b.MaxSize(); size++ {
+		if count := s.b.Count(size); count > 0 {
+			counts[size] = count
+		}
+	}
+
+	return append(append([]Header{}, s.b.Headers()...), countHeaders(counts)...)
+}
+
+func (s *binaryStore) Read() (*Shape, error) {
+	if s.b == nil {
+		return nil, errNotOpen
+	}
+
+	for {
+		shape, err := s.scanner.next()
+		if err != io.EOF || s.scanner.size >= s.b.MaxSize() {
+			return shape, err
+		}
+		s.scanner = s.b.scanner(s.scanner.size + 1)
+	}
+}
+
+// Create creates the file, the version of the tool and the equivalence are recorded with the headers when the store is
+// closed. Count headers are not recorded because the index records the number of shapes of every size.
+func (s *binaryStore) Create(path string, equivalence Equivalence, headers ...Header) error {
+	var err error
+	if s.f, err = os.Create(path); err != nil {
+		return err
+	}
+
+	s.shapes = NewShapesDiskWithBudget(BinaryMemoryBudget)().SetEquivalence(equivalence).(*ShapesDisk)
+	s.headers = make([]Header, 0, len(headers))
+	for _, header := range headers {
+		if header.Key != COUNT_HEADER {
+			s.headers = append(s.headers, header)
+		}
+	}
+
+	return nil
+}
+
+func (s *binaryStore) Append(path string) error {
+	return fmt.Errorf("%s: shapes can't be added to a binary file", path)
+}
+
+func (s *binaryStore) Write(shape *Shape) error {
+	if s.shapes == nil {
+		return errNotOpen
+	}
+	s.shapes.Add(*shape)
+
+	return s.shapes.Err()
+}
+
+// Flush does nothing, the shapes are written when the store is closed
+func (s *binaryStore) Flush() error {
+	if s.shapes == nil {
+		return errNotOpen
+	}
+
+	return nil
+}
+
+func (s *binaryStore) Close() error {
+	if s.b != nil {
+		return s.b.Close()
+	}
+	if s.shapes == nil {
+		return nil
+	}
+
+	err := writeBinary(s.f, s.shapes, s.headers)
+	if closeErr := s.f.Close(); err == nil {
+		err = closeErr
+	}
+
+	return errors.Join(err, s.shapes.Close())
+}
@@ -0,0 +1,173 @@
+package store_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/munnik/cubes/shape"
+	"github.com/munnik/cubes/store"
+)
+
+// returns the number of bytes of the shapes in the binary file, the shapes are at the end of the file
+func binaryShapesLength(t *testing.T, path string) int {
+	b, err := store.OpenBinary(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+
+	result := 0
+	for size := ShapeSize(1); size <= b.MaxSize(); size++ {
+		result += b.Count(size) * ScoreLength(size, 3)
+	}
+
+	return result
+}
+
+func TestBinaryRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "shapes.bin")
+	expected := grownShapes(5)
+	store.WriteBinary(expected, path, store.Header{Key: store.METHOD_HEADER, Value: "DefaultMap"})
+
+	shapes, err := store.ReadBinary(path, NewShapesDefaultMap())
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkShapes(t, expected, shapes)
+
+	if _, err := store.ReadBinary(path, NewShapesWithEquivalence(NewShapesDefaultMap, Free)()); err == nil {
+		t.Fatalf("Expected an error reading one-sided shapes as free shapes")
+	}
+
+	b, err := store.OpenBinary(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+	method := ""
+	for _, header := range b.Headers() {
+		if header.Key == store.METHOD_HEADER {
+			method = header.Value
+		}
+	}
+	if method != "DefaultMap" || b.Equivalence() != OneSided || b.MaxSize() != 5 {
+		t.Fatalf("Expected the headers of the file but got %v", b.Headers())
+	}
+}
+
+func TestBinaryShape(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "shapes.bin")
+	expected := grownShapes(6)
+	store.WriteBinary(expected, path)
+
+	b, err := store.OpenBinary(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+
+	for size := ShapeSize(1); size <= expected.MaxSize(); size++ {
+		scanned := make([]*Shape, 0)
+		if err := b.Scan(size, func(s *Shape) error {
+			scanned = append(scanned, s)
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+		if len(scanned) != b.Count(size) || len(scanned) != len(expected.GetAllWithSize(size)) {
+			t.Fatalf("Expected %d shapes with size %d but got %d", len(expected.GetAllWithSize(size)), size, len(scanned))
+		}
+
+		// the shapes are read in reverse order so every shape is read without reading the shape before it
+		for k := len(scanned) - 1; k >= 0; k-- {
+			s, err := b.Shape(size, k)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if s.Cmp(scanned[k]) != 0 {
+				t.Fatalf("Expected shape %d with size %d to be %v but got %v", k, size, scanned[k], s)
+			}
+		}
+
+		for _, k := range []int{-1, b.Count(size)} {
+			if _, err := b.Shape(size, k); err == nil {
+				t.Fatalf("Expected an error for shape %d with size %d", k, size)
+			}
+		}
+	}
+}
+
+func TestBinaryChecksum(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "shapes.bin")
+	store.WriteBinary(grownShapes(4), path)
+
+	// the first shape is the single cube at [0 0 0], flipping the last bit moves it to [1 0 0] which is a valid shape
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data[len(data)-binaryShapesLength(t, path)+ScoreLength(1, 3)-1] ^= 1
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := store.OpenBinary(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+	if err := b.Scan(1, func(s *Shape) error { return nil }); err == nil || !strings.Contains(err.Error(), "checksum of the shapes with size 1") {
+		t.Fatalf("Expected a checksum error but got %v", err)
+	}
+	if err := b.Scan(2, func(s *Shape) error { return nil }); err != nil {
+		t.Fatalf("Expected the shapes with size 2 to be intact but got %v", err)
+	}
+}
+
+func TestBinaryTruncatedIndex(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "shapes.bin")
+	store.WriteBinary(grownShapes(4), path)
+
+	// the index comes right before the shapes
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Truncate(path, info.Size()-int64(binaryShapesLength(t, path))-10); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := store.OpenBinary(path); err == nil || !strings.Contains(err.Error(), "writing the file was interrupted") {
+		t.Fatalf("Expected an error for a truncated index but got %v", err)
+	}
+}
+
+func TestBinaryCorruptHeader(t *testing.T) {
+	// format 1 without headers and an index of 2^32-1 sizes that doesn't fit in the file
+	path := filepath.Join(t.TempDir(), "shapes.bin")
+	if err := os.WriteFile(path, []byte("CUBESBIN\x00\x00\x00\x01\x00\x00\x00\x00\xff\xff\xff\xff"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.OpenBinary(path); err == nil || !strings.Contains(err.Error(), "writing the file was interrupted") {
+		t.Fatalf("Expected an error for an index that doesn't fit in the file but got %v", err)
+	}
+
+	// a number of headers that doesn't fit in the file
+	if err := os.WriteFile(path, []byte("CUBESBIN\x00\x00\x00\x01\xff\xff\xff\xff"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.OpenBinary(path); err == nil || !strings.Contains(err.Error(), "writing the file was interrupted") {
+		t.Fatalf("Expected an error for headers that don't fit in the file but got %v", err)
+	}
+}
+
+func TestBinaryMagic(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "shapes.txt")
+	store.WriteShapes(grownShapes(3), path, "")
+
+	if _, err := store.OpenBinary(path); err == nil || !strings.Contains(err.Error(), "not a binary file") {
+		t.Fatalf("Expected an error for a file that isn't binary but got %v", err)
+	}
+}
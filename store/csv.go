@@ -0,0 +1,288 @@
+package store
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	. "github.com/munnik/cubes/shape"
+)
+
+const CSV_FORMAT = "csv"
+
+func init() {
+	RegisterFormat(Format{Name: CSV_FORMAT, Extensions: []string{".csv"}, New: func() Store { return &csvStore{} }})
+}
+
+// csvStore stores shapes in a file with comma separated values with a row for every cube. The file starts with the
+// header lines of a text file followed by a row with the names of the columns. The columns are the number of the shape
+// in the file starting at 1, the size of the shape and a coordinate for every dimension of the shape, for example
+//
+//	shape,size,x,y,z
+//	1,2,0,0,0
+//	1,2,0,0,1
+//
+// The rows of the cubes of a shape come after each other.
+type csvStore struct {
+	lines      *lineReader
+	headers    []Header
+	properties *shapeProperties
+	w          *lineWriter
+	csv        *csv.Writer // writes the rows to w
+	number     int         // the number of the last shape that is written
+}
+
+// returns the names of the columns of a file with shapes with the dimensions
+func csvColumns(dimensions int) []string {
+	return append([]string{"shape", "size"}, axisNames[:dimensions]...)
+}
+
+// returns the fields of the line, a row doesn't span multiple lines because all fields are names or numbers
+func parseCSVLine(line string) ([]string, error) {
+	result, err := csv.NewReader(strings.NewReader(line)).Read()
+	if errors.Is(err, io.EOF) {
+		return nil, errors.New("row is empty")
+	}
+
+	return result, err
+}
+
+// csvRow is a row of a file with comma separated values
+type csvRow struct {
+	number int
+	size   int
+	coord  Coord
+}
+
+func (c *csvStore) Open(path string, complete bool) error {
+	var err error
+	if c.lines, err = openLines(path, complete); err != nil {
+		return err
+	}
+
+	c.headers = make([]Header, 0)
+	c.properties = newShapeProperties()
+	for c.lines.startsWith(HEADER_PREFIX) {
+		line, ok, err := c.lines.readLine()
+		if err != nil || !ok {
+			return err
+		}
+		header := strings.TrimSuffix(strings.TrimPrefix(line, HEADER_PREFIX), "\n")
+		if err := c.properties.read(header); err != nil {
+			return c.lines.errorf(c.lines.number, "%v", err)
+		}
+		key, value, _ := strings.Cut(header, " ")
+		c.headers = append(c.headers, Header{Key: key, Value: value})
+	}
+
+	line, ok, err := c.lines.readLine()
+	if err != nil || !ok {
+		return err
+	}
+	columns, err := parseCSVLine(line)
+	if err != nil {
+		return c.lines.errorf(c.lines.number, "%v", err)
+	}
+	if expected := csvColumns(c.properties.dimensions); strings.Join(columns, ",") != strings.Join(expected, ",") {
+		return c.lines.errorf(c.lines.number, "the columns should be %s", strings.Join(expected, ", "))
+	}
+
+	return nil
+}
+
+// returns the next row, nil at the end of the file
+func (c *csvStore) readRow() (*csvRow, error) {
+	line, ok, err := c.lines.readLine()
+	if err != nil || !ok {
+		return nil, err
+	}
+
+	fields, err := parseCSVLine(line)
+	if err != nil {
+		return nil, c.lines.errorf(c.lines.number, "%v", err)
+	}
+	if len(fields) != 2+c.properties.dimensions {
+		return nil, c.lines.errorf(c.lines.number, "row should have %d columns but has %d", 2+c.properties.dimensions, len(fields))
+	}
+	values := make([]int, len(fields))
+	for i, field := range fields {
+		if values[i], err = strconv.Atoi(field); err != nil {
+			return nil, c.lines.errorf(c.lines.number, "%v", err)
+		}
+	}
+	if values[0] < 1 || values[1] < 1 {
+		return nil, c.lines.errorf(c.lines.number, "the number and the size of a shape should be at least 1")
+	}
+
+	result := &csvRow{number: values[0], size: values[1]}
+	copy(result.coord[:], values[2:])
+
+	return result, nil
+}
+
+func (c *csvStore) Headers() []Header {
+	return c.headers
+}
+
+func (c *csvStore) Read() (*Shape, error) {
+	if c.lines == nil {
+		return nil, errNotOpen
+	}
+
+	first, err := c.readRow()
+	if err != nil {
+		return nil, err
+	}
+	if first == nil {
+		return nil, io.EOF
+	}
+
+	coords := []Coord{first.coord}
+	for len(coords) < first.size {
+		row, err := c.readRow()
+		if err != nil {
+			return nil, err
+		}
+		if row == nil {
+			if c.lines.complete {
+				return nil, c.lines.errorf(c.lines.number, "file ends after %d of the %d cubes of shape %d, writing the file was interrupted", len(coords), first.size, first.number)
+			}
+			return nil, io.EOF
+		}
+		if row.number != first.number || row.size != first.size {
+			return nil, c.lines.errorf(c.lines.number, "shape %d has %d cubes but the file has %d rows for it", first.number, first.size, len(coords))
+		}
+		coords = append(coords, row.coord)
+	}
+
+	shape, err := ShapeFromCoords(coords)
+	if err != nil {
+		return nil, c.lines.errorf(c.lines.number, "%v", err)
+	}
+	if int(shape.Size()) != first.size {
+		return nil, c.lines.errorf(c.lines.number, "shape %d has the same cube more than once", first.number)
+	}
+	c.properties.set(shape)
+	if err := shape.Validate(); err != nil {
+		return nil, c.lines.errorf(c.lines.number, "%v", err)
+	}
+
+	return shape, nil
+}
+
+func (c *csvStore) Create(path string, equivalence Equivalence, headers ...Header) error {
+	headers = startHeaders(equivalence, headers)
+	properties, err := readShapeProperties(headers)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	c.w = newLineWriter(f)
+	c.csv = csv.NewWriter(c.w.w)
+	if err := c.w.writeHeaders(headers); err != nil {
+		f.Close()
+		return err
+	}
+	if err := c.csv.Write(csvColumns(properties.dimensions)); err != nil {
+		f.Close()
+		return err
+	}
+
+	return nil
+}
+
+// Append removes the rows of a last shape that doesn't have all its cubes, because writing the file was interrupted
+func (c *csvStore) Append(path string) error {
+	rows := 0 // the number of rows of the last shape
+	// the file ends after the rows of the last shape with all its cubes, or after the columns if there are no shapes
+	f, err := openAppend(path, CSV_FORMAT, func(line string) (bool, error) {
+		if strings.HasPrefix(line, HEADER_PREFIX) {
+			return true, nil
+		}
+		fields, err := parseCSVLine(line)
+		if err != nil {
+			return false, err
+		}
+		number, err := strconv.Atoi(fields[0])
+		if err != nil {
+			// the names of the columns
+			return true, nil
+		}
+		if len(fields) < 2 {
+			return false, fmt.Errorf("row %q should have the number and the size of a shape", strings.TrimSuffix(line, "\n"))
+		}
+		size, err := strconv.Atoi(fields[1])
+		if err != nil {
+			return false, fmt.Errorf("row %q should have the number and the size of a shape", strings.TrimSuffix(line, "\n"))
+		}
+		rows++
+		if rows < size {
+			return false, nil
+		}
+		c.number = number
+		rows = 0
+		return true, nil
+	})
+	if err != nil {
+		return err
+	}
+	c.w = newLineWriter(f)
+	c.csv = csv.NewWriter(c.w.w)
+
+	return nil
+}
+
+func (c *csvStore) Write(s *Shape) error {
+	if c.w == nil {
+		return errNotOpen
+	}
+
+	c.number++
+	for _, coord := range s.Coords() {
+		fields := []string{strconv.Itoa(c.number), strconv.Itoa(int(s.Size()))}
+		for axis := 0; axis < s.Dimensions(); axis++ {
+			fields = append(fields, strconv.Itoa(coord[axis]))
+		}
+		if err := c.csv.Write(fields); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c *csvStore) Flush() error {
+	if c.w == nil {
+		return errNotOpen
+	}
+	c.csv.Flush()
+	if err := c.csv.Error(); err != nil {
+		return err
+	}
+
+	return c.w.flush()
+}
+
+func (c *csvStore) Close() error {
+	if c.lines != nil {
+		return c.lines.close()
+	}
+	if c.w != nil {
+		c.csv.Flush()
+		if err := c.csv.Error(); err != nil {
+			c.w.close()
+			return err
+		}
+		return c.w.close()
+	}
+
+	return nil
+}
@@ -0,0 +1,201 @@
+package store
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+
+	. "github.com/munnik/cubes/shape"
+)
+
+// the first bytes of a gzip stream, compressed files are recognized by their content when they are read
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// lineReader reads a file line by line, the file is decompressed if it is compressed with gzip
+type lineReader struct {
+	path     string
+	f        *os.File
+	gz       *gzip.Reader // nil if the file isn't compressed
+	r        *bufio.Reader
+	number   int  // the number of the last line that is read
+	complete bool // true if the last line should end with a new line
+}
+
+func openLines(path string, complete bool) (*lineReader, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &lineReader{path: path, f: f, r: bufio.NewReader(f), complete: complete}
+	if magic, _ := result.r.Peek(len(gzipMagic)); bytes.Equal(magic, gzipMagic) {
+		if result.gz, err = gzip.NewReader(result.r); err != nil {
+			f.Close()
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		result.r = bufio.NewReader(result.gz)
+	}
+
+	return result, nil
+}
+
+// returns true if the next line starts with the prefix
+func (l *lineReader) startsWith(prefix string) bool {
+	next, err := l.r.Peek(len(prefix))
+	return err == nil && string(next) == prefix
+}
+
+// reads the next line including the new line, returns false at the end of the file. A last line that doesn't end with
+// a new line is skipped if the file doesn't have to be complete, because writing the file was interrupted.
+func (l *lineReader) readLine() (string, bool, error) {
+	line, err := l.r.ReadString('\n')
+	if err == nil {
+		l.number++
+		return line, true, nil
+	}
+
+	switch {
+	case err == io.ErrUnexpectedEOF && l.complete:
+		// the compressed stream ends before its end
+		return "", false, l.errorf(l.number+1, "file ends unexpectedly, writing the file was interrupted")
+	case err == io.EOF && line != "" && l.complete:
+		return "", false, l.errorf(l.number+1, "line doesn't end with a new line, writing the file was interrupted")
+	case err == io.EOF || err == io.ErrUnexpectedEOF:
+		return "", false, nil
+	}
+
+	return "", false, err
+}
+
+// returns an error for the line with the number, prefixed with the path and the line number
+func (l *lineReader) errorf(number int, format string, a ...any) error {
+	return fmt.Errorf("%s:%d: %s", l.path, number, fmt.Sprintf(format, a...))
+}
+
+func (l *lineReader) close() error {
+	if l.gz != nil {
+		l.gz.Close()
+	}
+
+	return l.f.Close()
+}
+
+// openAppend opens an existing file in the format to write lines to the end of the file. keep is called for every line
+// that ends with a new line and returns true if the file can end after the line, the file is truncated after the last
+// line that is kept, so a last line that doesn't end with a new line is always removed. Shapes can't be added to a
+// compressed file or to a file with the counts in the headers, because the counts would no longer match the shapes.
+func openAppend(path string, format string, keep func(line string) (bool, error)) (*os.File, error) {
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	r := bufio.NewReader(f)
+	if magic, _ := r.Peek(len(gzipMagic)); bytes.Equal(magic, gzipMagic) {
+		f.Close()
+		return nil, fmt.Errorf("%s: shapes can't be added to a compressed file", path)
+	}
+	headers, err := ReadHeaders(path, format)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	for _, header := range headers {
+		if header.Key == COUNT_HEADER {
+			f.Close()
+			return nil, fmt.Errorf("%s: shapes can't be added to a file with the counts in the headers", path)
+		}
+	}
+
+	end := int64(0) // the end of the last line that is kept
+	for offset := int64(0); ; {
+		line, err := r.ReadString('\n')
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		offset += int64(len(line))
+		ok, err := keep(line)
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		if ok {
+			end = offset
+		}
+	}
+
+	if err := truncate(f, end); err != nil {
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// truncates the file at the offset and moves to the end of the file, the file is closed if an error is returned
+func truncate(f *os.File, offset int64) error {
+	if err := f.Truncate(offset); err != nil {
+		f.Close()
+		return err
+	}
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		f.Close()
+		return err
+	}
+
+	return nil
+}
+
+// lineWriter writes lines to a file through a buffer
+type lineWriter struct {
+	f *os.File
+	w *bufio.Writer
+}
+
+func newLineWriter(f *os.File) *lineWriter {
+	return &lineWriter{f: f, w: bufio.NewWriter(f)}
+}
+
+func (l *lineWriter) writeLine(line string) error {
+	_, err := l.w.WriteString(line + "\n")
+	return err
+}
+
+// writes the headers as lines that start with the header prefix
+func (l *lineWriter) writeHeaders(headers []Header) error {
+	for _, header := range headers {
+		if err := l.writeLine(HEADER_PREFIX + header.Key + " " + header.Value); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (l *lineWriter) flush() error {
+	return l.w.Flush()
+}
+
+func (l *lineWriter) close() error {
+	if err := l.w.Flush(); err != nil {
+		l.f.Close()
+		return err
+	}
+
+	return l.f.Close()
+}
+
+// returns the headers with the version of the tool and the equivalence followed by the headers, the headers that are
+// written at the start of every file
+func startHeaders(equivalence Equivalence, headers []Header) []Header {
+	return append([]Header{
+		{Key: VERSION_HEADER, Value: toolVersion()},
+		{Key: EQUIVALENCE_HEADER, Value: equivalence.String()},
+	}, headers...)
+}
@@ -0,0 +1,185 @@
+package store
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+
+	. "github.com/munnik/cubes/shape"
+)
+
+const NDJSON_FORMAT = "ndjson"
+
+func init() {
+	RegisterFormat(Format{Name: NDJSON_FORMAT, Extensions: []string{".ndjson", ".jsonl"}, New: func() Store { return &ndjsonStore{} }})
+}
+
+// ndjsonLine is a line of a file with newline delimited JSON, a line has a header and its value or the coordinates of
+// the cubes of a shape, for example {"header":"equivalence","value":"free"} and {"cubes":[[0,0,0],[0,0,1]]}. The
+// coordinates have a value for every dimension of the shape. The headers come before the shapes.
+type ndjsonLine struct {
+	Header string  `json:"header,omitempty"`
+	Value  string  `json:"value,omitempty"`
+	Cubes  [][]int `json:"cubes,omitempty"`
+}
+
+type ndjsonStore struct {
+	lines      *lineReader
+	headers    []Header
+	properties *shapeProperties
+	next       *ndjsonLine // the line with the first shape, it is read with the headers
+	w          *lineWriter
+}
+
+func (n *ndjsonStore) Open(path string, complete bool) error {
+	var err error
+	if n.lines, err = openLines(path, complete); err != nil {
+		return err
+	}
+
+	n.headers = make([]Header, 0)
+	n.properties = newShapeProperties()
+	for {
+		line, err := n.readLine()
+		if err != nil || line == nil {
+			return err
+		}
+		if line.Header == "" {
+			n.next = line
+			return nil
+		}
+		if err := n.properties.read(line.Header + " " + line.Value); err != nil {
+			return n.lines.errorf(n.lines.number, "%v", err)
+		}
+		n.headers = append(n.headers, Header{Key: line.Header, Value: line.Value})
+	}
+}
+
+// returns the next line, nil at the end of the file
+func (n *ndjsonStore) readLine() (*ndjsonLine, error) {
+	s, ok, err := n.lines.readLine()
+	if err != nil || !ok {
+		return nil, err
+	}
+
+	result := &ndjsonLine{}
+	if err := json.Unmarshal([]byte(s), result); err != nil {
+		return nil, n.lines.errorf(n.lines.number, "%v", err)
+	}
+
+	return result, nil
+}
+
+func (n *ndjsonStore) Headers() []Header {
+	return n.headers
+}
+
+func (n *ndjsonStore) Read() (*Shape, error) {
+	if n.lines == nil {
+		return nil, errNotOpen
+	}
+
+	line := n.next
+	n.next = nil
+	if line == nil {
+		var err error
+		if line, err = n.readLine(); err != nil {
+			return nil, err
+		}
+		if line == nil {
+			return nil, io.EOF
+		}
+	}
+	if line.Header != "" {
+		return nil, n.lines.errorf(n.lines.number, "header %s is not expected after the shapes", line.Header)
+	}
+
+	coords := make([]Coord, 0, len(line.Cubes))
+	for _, cube := range line.Cubes {
+		if len(cube) != n.properties.dimensions {
+			return nil, n.lines.errorf(n.lines.number, "cube %v should have a coordinate for every one of the %d dimensions", cube, n.properties.dimensions)
+		}
+		var c Coord
+		copy(c[:], cube)
+		coords = append(coords, c)
+	}
+	shape, err := ShapeFromCoords(coords)
+	if err != nil {
+		return nil, n.lines.errorf(n.lines.number, "%v", err)
+	}
+	n.properties.set(shape)
+	if err := shape.Validate(); err != nil {
+		return nil, n.lines.errorf(n.lines.number, "%v", err)
+	}
+
+	return shape, nil
+}
+
+func (n *ndjsonStore) Create(path string, equivalence Equivalence, headers ...Header) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	n.w = newLineWriter(f)
+	for _, header := range startHeaders(equivalence, headers) {
+		line, err := json.Marshal(ndjsonLine{Header: header.Key, Value: header.Value})
+		if err != nil {
+			f.Close()
+			return err
+		}
+		if err := n.w.writeLine(string(line)); err != nil {
+			f.Close()
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (n *ndjsonStore) Append(path string) error {
+	f, err := openAppend(path, NDJSON_FORMAT, func(line string) (bool, error) { return true, nil })
+	if err != nil {
+		return err
+	}
+	n.w = newLineWriter(f)
+
+	return nil
+}
+
+func (n *ndjsonStore) Write(s *Shape) error {
+	if n.w == nil {
+		return errNotOpen
+	}
+
+	line := ndjsonLine{Cubes: make([][]int, 0, s.Size())}
+	for _, c := range s.Coords() {
+		c := c
+		line.Cubes = append(line.Cubes, c[:s.Dimensions()])
+	}
+	b, err := json.Marshal(line)
+	if err != nil {
+		return err
+	}
+
+	return n.w.writeLine(string(b))
+}
+
+func (n *ndjsonStore) Flush() error {
+	if n.w == nil {
+		return errNotOpen
+	}
+
+	return n.w.flush()
+}
+
+func (n *ndjsonStore) Close() error {
+	if n.lines != nil {
+		return n.lines.close()
+	}
+	if n.w != nil {
+		return n.w.close()
+	}
+
+	return nil
+}
@@ -0,0 +1,328 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	. "github.com/munnik/cubes/shape"
+)
+
+// Store reads shapes from a file or writes shapes to a file in a format. A store reads the shapes of a file after Open
+// and writes shapes to a file after Create or Append, until Close is called.
+type Store interface {
+	// Open opens the file to read the shapes in it. If complete is true an error is returned when writing the file was
+	// interrupted, otherwise the shapes that were written before the interruption are read.
+	Open(path string, complete bool) error
+	// Headers returns the headers of the file that is opened
+	Headers() []Header
+	// Read returns the next shape in the file that is opened, io.EOF is returned after the last shape
+	Read() (*Shape, error)
+	// Create creates the file to write shapes to, the version of the tool, the equivalence and the headers are recorded
+	// at the start of the file
+	Create(path string, equivalence Equivalence, headers ...Header) error
+	// Append opens an existing file to write shapes to the end of the file
+	Append(path string) error
+	// Write writes the shape to the file that is created or appended to
+	Write(s *Shape) error
+	// Flush writes all buffered shapes to the file
+	Flush() error
+	Close() error
+}
+
+// DEFAULT_FORMAT is the format of files with an extension that no format is registered for
+const DEFAULT_FORMAT = TEXT_FORMAT
+
+// Format is a format shapes can be stored in, a store for the format is created by New
+type Format struct {
+	Name       string
+	Extensions []string // the extensions of the files that are stored in the format, starting with a dot
+	Magic      string   // the first bytes of every file in the format, empty if the files don't start the same
+	New        func() Store
+}
+
+// the registered formats by name
+var formats = make(map[string]Format)
+
+// RegisterFormat makes the format available by its name and extensions, the formats of this package register themselves.
+// RegisterFormat panics if a format with the same name is already registered.
+func RegisterFormat(format Format) {
+	if _, ok := formats[format.Name]; ok {
+		panic(fmt.Sprintf("format %s is registered twice", format.Name))
+	}
+	formats[format.Name] = format
+}
+
+// FormatNames returns the names of the registered formats in alphabetical order
+func FormatNames() []string {
+	result := make([]string, 0, len(formats))
+	for name := range formats {
+		result = append(result, name)
+	}
+	sort.Strings(result)
+
+	return result
+}
+
+// Extension returns the extension of the path a format is registered for, the longest extension if the path has several,
+// an empty string if no format is registered for the extension of the path
+func Extension(path string) string {
+	result := ""
+	for _, format := range formats {
+		for _, extension := range format.Extensions {
+			if strings.HasSuffix(path, extension) && len(extension) > len(result) {
+				result = extension
+			}
+		}
+	}
+
+	return result
+}
+
+// DetectFormat returns the name of the format that is registered for the extension of the path, DEFAULT_FORMAT if no
+// format is registered for the extension
+func DetectFormat(path string) string {
+	if extension := Extension(path); extension != "" {
+		for _, format := range formats {
+			for _, e := range format.Extensions {
+				if e == extension {
+					return format.Name
+				}
+			}
+		}
+	}
+
+	return DEFAULT_FORMAT
+}
+
+// returns the name of the format of the existing file, the format with the magic the file starts with or the format that
+// is registered for the extension of the path
+func detectFileFormat(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	start := make([]byte, 0)
+	for _, format := range formats {
+		if len(format.Magic) > len(start) {
+			start = make([]byte, len(format.Magic))
+		}
+	}
+	n, err := io.ReadFull(f, start)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return "", err
+	}
+	for _, format := range formats {
+		if format.Magic != "" && strings.HasPrefix(string(start[:n]), format.Magic) {
+			return format.Name, nil
+		}
+	}
+
+	return DetectFormat(path), nil
+}
+
+// NewStore returns a store for the format with the name
+func NewStore(name string) (Store, error) {
+	format, ok := formats[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown format %s, options are %s", name, strings.Join(FormatNames(), ", "))
+	}
+
+	return format.New(), nil
+}
+
+// returns a store for the format with the name, the format is detected from the path if the name is empty
+func newStore(path string, name string) (Store, error) {
+	if name == "" {
+		name = DetectFormat(path)
+	}
+
+	return NewStore(name)
+}
+
+// returns a store for the format with the name to read the existing file, the format is detected from the start of the
+// file and the path if the name is empty
+func newFileStore(path string, name string) (Store, error) {
+	if name == "" {
+		var err error
+		if name, err = detectFileFormat(path); err != nil {
+			return nil, err
+		}
+	}
+
+	return NewStore(name)
+}
+
+// Open opens the file with a store for the format to read the shapes in the file, the format is detected from the start
+// of the file and the path if format is empty
+func Open(path string, format string, complete bool) (Store, error) {
+	s, err := newFileStore(path, format)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.Open(path, complete); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// Create creates the file with a store for the format to write shapes to, the format is detected from the path if
+// format is empty
+func Create(path string, format string, equivalence Equivalence, headers ...Header) (Store, error) {
+	s, err := newStore(path, format)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.Create(path, equivalence, headers...); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// Append opens an existing file with a store for the format to write shapes to the end of the file, the format is
+// detected from the start of the file and the path if format is empty. Shapes can't be added to a file with the counts
+// in the headers, like the files written by WriteShapes, because the counts would no longer match the shapes.
+func Append(path string, format string) (Store, error) {
+	s, err := newFileStore(path, format)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.Append(path); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// ReadHeaders returns the headers at the start of the file
+func ReadHeaders(path string, format string) ([]Header, error) {
+	s, err := Open(path, format, false)
+	if err != nil {
+		return nil, err
+	}
+	defer s.Close()
+
+	return s.Headers(), nil
+}
+
+// ReadShapes adds the shapes in the file to shapes. Files without an equivalence header are assumed to use the
+// equivalence of shapes, an error is returned if the header records a different equivalence. The shapes have the
+// dimensions and adjacency recorded in the headers, 3 dimensions and face adjacency if there are no headers. An error is
+// returned if writing the file was interrupted or the number of shapes of a size doesn't match the count in the headers.
+func ReadShapes(path string, format string, shapes Shapes) (Shapes, error) {
+	s, err := Open(path, format, true)
+	if err != nil {
+		return nil, err
+	}
+	defer s.Close()
+
+	expected := make(map[ShapeSize]int)
+	for _, header := range s.Headers() {
+		switch header.Key {
+		case EQUIVALENCE_HEADER:
+			equivalence, err := EquivalenceFromString(header.Value)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", path, err)
+			}
+			if equivalence != shapes.Equivalence() {
+				return nil, fmt.Errorf("%s: file contains %v shapes but %v shapes are requested", path, equivalence, shapes.Equivalence())
+			}
+		case COUNT_HEADER:
+			var size, count int
+			if _, err := fmt.Sscanf(header.Value, "%d %d", &size, &count); err != nil {
+				return nil, fmt.Errorf("%s: count header %q should contain a size and a count: %w", path, header.Value, err)
+			}
+			expected[ShapeSize(size)] = count
+		}
+	}
+
+	counts := make(map[ShapeSize]int)
+	for {
+		shape, err := s.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		counts[shape.Size()]++
+		shapes.Add(*shape)
+	}
+
+	if len(expected) > 0 {
+		for _, c := range []map[ShapeSize]int{expected, counts} {
+			for size := range c {
+				if expected[size] != counts[size] {
+					return nil, fmt.Errorf("%s: file records %d shapes with size %d but contains %d", path, expected[size], size, counts[size])
+				}
+			}
+		}
+	}
+
+	return shapes, nil
+}
+
+// ScanShapes calls fn for every shape in the file without keeping the shapes in memory. The shapes that were written
+// before writing the file was interrupted are read.
+func ScanShapes(path string, format string, fn func(*Shape) error) error {
+	s, err := Open(path, format, false)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	for {
+		shape, err := s.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := fn(shape); err != nil {
+			return err
+		}
+	}
+}
+
+// WriteShapes writes the shapes ordered by size and score in the format, the format is detected from the path if format
+// is empty. The number of shapes of every size is recorded in the headers.
+func WriteShapes(s Shapes, path string, format string, headers ...Header) {
+	counts := make(map[ShapeSize]int)
+	for size := ShapeSize(1); size <= s.MaxSize(); size++ {
+		s.Iterate(size, func(shape *Shape) bool {
+			counts[size]++
+			return true
+		})
+	}
+	headers = append(headers, countHeaders(counts)...)
+
+	w, err := Create(path, format, s.Equivalence(), headers...)
+	if err != nil {
+		panic(err)
+	}
+
+	for size := ShapeSize(1); size <= s.MaxSize(); size++ {
+		s.Iterate(size, func(shape *Shape) bool {
+			if err := w.Write(shape); err != nil {
+				panic(err)
+			}
+			return true
+		})
+	}
+
+	if err := w.Close(); err != nil {
+		panic(err)
+	}
+}
+
+// the error that is returned by a store that is used to read a file but is not opened, or used to write a file but not
+// created or appended to
+var errNotOpen = errors.New("store is not opened for this operation")
@@ -0,0 +1,177 @@
+package store_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/munnik/cubes/shape"
+	"github.com/munnik/cubes/store"
+)
+
+// the extension of the files of every format that has one
+var formatExtensions = map[string]string{
+	store.BINARY_FORMAT: ".bin",
+	store.CSV_FORMAT:    ".csv",
+	store.GZIP_FORMAT:   ".txt.gz",
+	store.NDJSON_FORMAT: ".ndjson",
+}
+
+func TestFormatRoundTrip(t *testing.T) {
+	expected := grownShapes(5)
+	for _, name := range store.FormatNames() {
+		// the format is chosen by the extension and by the name
+		for _, format := range []string{"", name} {
+			path := filepath.Join(t.TempDir(), "shapes"+formatExtensions[name])
+			store.WriteShapes(expected, path, format, store.Header{Key: store.METHOD_HEADER, Value: "DefaultMap"})
+
+			shapes, err := store.ReadShapes(path, format, NewShapesDefaultMap())
+			if err != nil {
+				t.Fatalf("%s: %v", name, err)
+			}
+			checkShapes(t, expected, shapes)
+
+			headers, err := store.ReadHeaders(path, format)
+			if err != nil {
+				t.Fatalf("%s: %v", name, err)
+			}
+			method := ""
+			for _, header := range headers {
+				if header.Key == store.METHOD_HEADER {
+					method = header.Value
+				}
+			}
+			if method != "DefaultMap" {
+				t.Fatalf("%s: expected the headers of the file but got %v", name, headers)
+			}
+		}
+	}
+}
+
+func TestDetectFormat(t *testing.T) {
+	for path, expected := range map[string]string{
+		"shapes.txt":    store.TEXT_FORMAT,
+		"shapes":        store.TEXT_FORMAT,
+		"shapes.dat":    store.TEXT_FORMAT,
+		"shapes.csv":    store.CSV_FORMAT,
+		"shapes.ndjson": store.NDJSON_FORMAT,
+		"shapes.jsonl":  store.NDJSON_FORMAT,
+		"shapes.txt.gz": store.GZIP_FORMAT,
+		"shapes.bin":    store.BINARY_FORMAT,
+	} {
+		if format := store.DetectFormat(path); format != expected {
+			t.Fatalf("Expected %s to have format %s but got %s", path, expected, format)
+		}
+	}
+
+	if extension := store.Extension("shapes.csv.gz"); extension != ".gz" {
+		t.Fatalf("Expected extension .gz but got %q", extension)
+	}
+	if extension := store.Extension("shapes.dat"); extension != "" {
+		t.Fatalf("Expected no extension but got %q", extension)
+	}
+	if _, err := store.NewStore("unknown"); err == nil {
+		t.Fatalf("Expected an error for an unknown format")
+	}
+}
+
+func TestDetectFileFormat(t *testing.T) {
+	// a binary file is recognized by its first bytes whatever its extension is
+	path := filepath.Join(t.TempDir(), "shapes.txt")
+	expected := grownShapes(4)
+	store.WriteBinary(expected, path)
+
+	shapes, err := store.ReadShapes(path, "", NewShapesDefaultMap())
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkShapes(t, expected, shapes)
+
+	if _, err := store.ReadShapes(path, store.TEXT_FORMAT, NewShapesDefaultMap()); err == nil {
+		t.Fatalf("Expected an error reading a binary file as text")
+	}
+}
+
+func TestFormatTruncated(t *testing.T) {
+	for _, name := range []string{store.CSV_FORMAT, store.NDJSON_FORMAT} {
+		path := filepath.Join(t.TempDir(), "shapes"+formatExtensions[name])
+		store.WriteShapes(grownShapes(4), path, "")
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, data[:len(data)-3], 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := store.ReadShapes(path, "", NewShapesDefaultMap()); err == nil || !strings.Contains(err.Error(), "interrupted") {
+			t.Fatalf("%s: expected an error for an interrupted file but got %v", name, err)
+		}
+	}
+}
+
+// writes the shapes with the sizes from up to and including to with the store
+func writeStore(t *testing.T, s store.Store, shapes Shapes, from ShapeSize, to ShapeSize) {
+	for size := from; size <= to; size++ {
+		shapes.Iterate(size, func(shape *Shape) bool {
+			if err := s.Write(shape); err != nil {
+				t.Fatal(err)
+			}
+			return true
+		})
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFormatAppend(t *testing.T) {
+	expected := grownShapes(5)
+	for _, name := range []string{store.TEXT_FORMAT, store.CSV_FORMAT, store.NDJSON_FORMAT} {
+		path := filepath.Join(t.TempDir(), "shapes"+formatExtensions[name])
+		s, err := store.Create(path, "", OneSided)
+		if err != nil {
+			t.Fatal(err)
+		}
+		writeStore(t, s, expected, 1, 3)
+
+		// an interrupted write leaves a line without a new line behind, it is removed when shapes are added
+		f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := f.WriteString("[0 0"); err != nil {
+			t.Fatal(err)
+		}
+		if err := f.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		if s, err = store.Append(path, ""); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		writeStore(t, s, expected, 4, 5)
+
+		shapes, err := store.ReadShapes(path, "", NewShapesDefaultMap())
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		checkShapes(t, expected, shapes)
+	}
+}
+
+func TestFormatAppendCounts(t *testing.T) {
+	for _, name := range []string{store.TEXT_FORMAT, store.CSV_FORMAT, store.NDJSON_FORMAT, store.GZIP_FORMAT} {
+		path := filepath.Join(t.TempDir(), "shapes"+formatExtensions[name])
+		store.WriteShapes(grownShapes(3), path, "")
+
+		if _, err := store.Append(path, ""); err == nil {
+			t.Fatalf("%s: expected an error adding shapes to a file with counts in the headers", name)
+		}
+		if _, err := store.ReadShapes(path, "", NewShapesDefaultMap()); err != nil {
+			t.Fatalf("%s: expected the file to be left as it was but got %v", name, err)
+		}
+	}
+}
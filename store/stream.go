@@ -0,0 +1,363 @@
+package store
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	. "github.com/munnik/cubes/shape"
+)
+
+// GZIP_EXTENSION is the extension of the text files that are compressed when they are written
+const GZIP_EXTENSION = ".gz"
+
+// Compressed returns true if a text file with the path is compressed when it is written
+func Compressed(path string) bool {
+	return strings.HasSuffix(path, GZIP_EXTENSION)
+}
+
+// Writer writes shapes one at a time to a text file, one shape per line. The file starts with header lines that record
+// the format, the version of the tool and the equivalence the shapes are unique under followed by the additional
+// headers. When the writer is closed a trailer is written with the number of shapes of every size, unless the counts are
+// in the headers, and the checksum of the lines with shapes. Files with the gzip extension are compressed with gzip,
+// the checksum is calculated over the uncompressed lines.
+type Writer struct {
+	f              *os.File
+	gz             *gzip.Writer // nil if the file isn't compressed
+	w              *bufio.Writer
+	checksum       hash.Hash32
+	counts         map[ShapeSize]int
+	countsInHeader bool
+}
+
+func NewWriter(path string, equivalence Equivalence, headers ...Header) (*Writer, error) {
+	return createWriter(path, Compressed(path), equivalence, headers...)
+}
+
+func createWriter(path string, compressed bool, equivalence Equivalence, headers ...Header) (*Writer, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+
+	result := newWriter(f, compressed)
+	headers = append([]Header{
+		{Key: FORMAT_HEADER, Value: strconv.Itoa(FORMAT_VERSION)},
+		{Key: VERSION_HEADER, Value: toolVersion()},
+		{Key: EQUIVALENCE_HEADER, Value: equivalence.String()},
+	}, headers...)
+	for _, header := range headers {
+		if header.Key == COUNT_HEADER {
+			result.countsInHeader = true
+		}
+		if _, err := fmt.Fprintf(result.w, "%s%s %s\n", HEADER_PREFIX, header.Key, header.Value); err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
+
+// OpenWriter opens an existing text file to add shapes to the end of the file. The trailer and a last line that doesn't
+// end with a new line are removed, the trailer is written again when the writer is closed. Shapes can't be added to a
+// compressed file or to a file with the counts in the headers, because the counts would no longer match the shapes.
+func OpenWriter(path string) (*Writer, error) {
+	checksum := crc32.NewIEEE()
+	counts := make(map[ShapeSize]int)
+	shapes := false
+	// the file ends after the last line with a shape, or after the headers if there are no shapes
+	f, err := openAppend(path, TEXT_FORMAT, func(line string) (bool, error) {
+		if header, ok := strings.CutPrefix(line, HEADER_PREFIX); ok {
+			key, _, _ := strings.Cut(header, " ")
+			return !shapes && key != CHECKSUM_HEADER, nil
+		}
+		shapes = true
+		checksum.Write([]byte(line))
+		// the size of a shape is the number of coordinates on the line
+		counts[ShapeSize(strings.Count(line, SEPARATOR)+1)]++
+		return true, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result := newWriter(f, false)
+	result.checksum = checksum
+	result.counts = counts
+
+	return result, nil
+}
+
+func newWriter(f *os.File, compressed bool) *Writer {
+	result := &Writer{
+		f:        f,
+		checksum: crc32.NewIEEE(),
+		counts:   make(map[ShapeSize]int),
+	}
+	if compressed {
+		result.gz = gzip.NewWriter(f)
+		result.w = bufio.NewWriter(result.gz)
+	} else {
+		result.w = bufio.NewWriter(f)
+	}
+
+	return result
+}
+
+func (w *Writer) Write(s *Shape) error {
+	line := s.String() + "\n"
+	w.checksum.Write([]byte(line))
+	w.counts[s.Size()]++
+	_, err := w.w.WriteString(line)
+
+	return err
+}
+
+// Flush writes all buffered shapes to the file, the shapes of a compressed file can be read until the last flush when
+// writing the file is interrupted
+func (w *Writer) Flush() error {
+	if err := w.w.Flush(); err != nil {
+		return err
+	}
+	if w.gz != nil {
+		return w.gz.Flush()
+	}
+
+	return nil
+}
+
+// Close writes the trailer and closes the file
+func (w *Writer) Close() error {
+	if !w.countsInHeader {
+		for _, header := range countHeaders(w.counts) {
+			if _, err := fmt.Fprintf(w.w, "%s%s %s\n", HEADER_PREFIX, header.Key, header.Value); err != nil {
+				w.f.Close()
+				return err
+			}
+		}
+	}
+	if _, err := fmt.Fprintf(w.w, "%s%s %s %08x\n", HEADER_PREFIX, CHECKSUM_HEADER, checksumAlgorithm, w.checksum.Sum32()); err != nil {
+		w.f.Close()
+		return err
+	}
+	if err := w.w.Flush(); err != nil {
+		w.f.Close()
+		return err
+	}
+	if w.gz != nil {
+		if err := w.gz.Close(); err != nil {
+			w.f.Close()
+			return err
+		}
+	}
+
+	return w.f.Close()
+}
+
+// Reader reads the shapes of a text file one at a time and checks them against the headers and the trailer, the file
+// is decompressed if it is compressed with gzip. A last line that doesn't end with a new line is skipped and a missing
+// trailer is allowed, because writing the file was interrupted. The shapes are checked against the trailer if the file
+// has one.
+type Reader struct {
+	*lineReader
+
+	headers          []Header
+	format           int
+	properties       *shapeProperties
+	maxSize          ShapeSize // 0 if the file has no max size header
+	headerCounts     map[ShapeSize]int
+	trailerCounts    map[ShapeSize]int
+	counts           map[ShapeSize]int
+	checksum         hash.Hash32
+	expectedChecksum string
+	shapes           bool // true once the first line with a shape is read
+}
+
+// NewReader opens the file and reads the headers
+func NewReader(path string) (*Reader, error) {
+	return openReader(path, false)
+}
+
+// opens the file and reads the headers, if complete is true the file should have a trailer and end with a new line
+func openReader(path string, complete bool) (*Reader, error) {
+	lines, err := openLines(path, complete)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Reader{
+		lineReader:    lines,
+		headers:       make([]Header, 0),
+		format:        1,
+		properties:    newShapeProperties(),
+		headerCounts:  make(map[ShapeSize]int),
+		trailerCounts: make(map[ShapeSize]int),
+		counts:        make(map[ShapeSize]int),
+		checksum:      crc32.NewIEEE(),
+	}
+	if err := result.readHeaders(); err != nil {
+		result.Close()
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// reads the header lines before the first shape
+func (r *Reader) readHeaders() error {
+	for r.startsWith(HEADER_PREFIX) {
+		line, ok, err := r.readLine()
+		if err != nil || !ok {
+			return err
+		}
+		header := strings.TrimSuffix(strings.TrimPrefix(line, HEADER_PREFIX), "\n")
+		if err := r.readHeader(header); err != nil {
+			return r.errorf(r.number, "%v", err)
+		}
+		key, value, _ := strings.Cut(header, " ")
+		if key != CHECKSUM_HEADER {
+			// the checksum is the trailer of a file without shapes
+			r.headers = append(r.headers, Header{Key: key, Value: value})
+		}
+	}
+
+	return nil
+}
+
+// Headers returns the headers at the start of the file
+func (r *Reader) Headers() []Header {
+	return r.headers
+}
+
+// Next returns the next shape in the file, io.EOF is returned after the last shape when the shapes match the trailer
+func (r *Reader) Next() (*Shape, error) {
+	for {
+		line, ok, err := r.readLine()
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			if err := r.check(); err != nil {
+				return nil, err
+			}
+			return nil, io.EOF
+		}
+
+		if header, ok := strings.CutPrefix(line, HEADER_PREFIX); ok {
+			if err := r.readHeader(strings.TrimSuffix(header, "\n")); err != nil {
+				return nil, r.errorf(r.number, "%v", err)
+			}
+			continue
+		}
+
+		r.shapes = true
+		r.checksum.Write([]byte(line))
+		shape, err := ShapeFromString(strings.TrimSuffix(line, "\n"))
+		if err != nil {
+			return nil, r.errorf(r.number, "%v", err)
+		}
+		r.properties.set(shape)
+		if err := shape.Validate(); err != nil {
+			return nil, r.errorf(r.number, "%v", err)
+		}
+		if r.maxSize > 0 && shape.Size() > r.maxSize {
+			return nil, r.errorf(r.number, "shape has %d cubes but the file has shapes with at most %d cubes", shape.Size(), r.maxSize)
+		}
+		r.counts[shape.Size()]++
+
+		return shape, nil
+	}
+}
+
+// reads a header or trailer line without the prefix
+func (r *Reader) readHeader(header string) error {
+	key, value, _ := strings.Cut(header, " ")
+	if r.shapes && key != COUNT_HEADER && key != CHECKSUM_HEADER {
+		return fmt.Errorf("header %s is not expected after the shapes", key)
+	}
+
+	switch key {
+	case FORMAT_HEADER:
+		format, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid format header: %w", err)
+		}
+		if format > FORMAT_VERSION {
+			return fmt.Errorf("file has format %d but at most format %d is supported", format, FORMAT_VERSION)
+		}
+		r.format = format
+	case EQUIVALENCE_HEADER:
+		if _, err := EquivalenceFromString(value); err != nil {
+			return err
+		}
+	case MAX_SIZE_HEADER:
+		maxSize, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid max size header: %w", err)
+		}
+		r.maxSize = ShapeSize(maxSize)
+	case COUNT_HEADER:
+		var size, count int
+		if _, err := fmt.Sscanf(value, "%d %d", &size, &count); err != nil {
+			return fmt.Errorf("count header %q should contain a size and a count: %w", value, err)
+		}
+		if r.shapes {
+			r.trailerCounts[ShapeSize(size)] = count
+		} else {
+			r.headerCounts[ShapeSize(size)] = count
+		}
+	case CHECKSUM_HEADER:
+		algorithm, checksum, _ := strings.Cut(value, " ")
+		if algorithm != checksumAlgorithm {
+			return fmt.Errorf("unknown checksum algorithm %s", algorithm)
+		}
+		r.expectedChecksum = checksum
+	}
+
+	return r.properties.read(header)
+}
+
+// checks the shapes that are read against the checksum and the counts in the file
+func (r *Reader) check() error {
+	if r.format < 2 {
+		return nil
+	}
+	if r.expectedChecksum == "" {
+		if r.complete {
+			return fmt.Errorf("%s: checksum trailer is missing, writing the file was interrupted", r.path)
+		}
+		return nil
+	}
+
+	if checksum := fmt.Sprintf("%08x", r.checksum.Sum32()); checksum != r.expectedChecksum {
+		return fmt.Errorf("%s: checksum of the shapes is %s but the file records %s", r.path, checksum, r.expectedChecksum)
+	}
+
+	expected := r.headerCounts
+	if len(expected) == 0 {
+		expected = r.trailerCounts
+	}
+	if len(expected) == 0 {
+		return nil
+	}
+	for _, counts := range []map[ShapeSize]int{expected, r.counts} {
+		for size := range counts {
+			if expected[size] != r.counts[size] {
+				return fmt.Errorf("%s: file records %d shapes with size %d but contains %d", r.path, expected[size], size, r.counts[size])
+			}
+		}
+	}
+
+	return nil
+}
+
+func (r *Reader) Close() error {
+	return r.close()
+}
@@ -0,0 +1,160 @@
+package store_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/munnik/cubes/shape"
+	"github.com/munnik/cubes/store"
+)
+
+// writes the shapes with the sizes from up to and including to with the writer
+func writeSizes(t *testing.T, w *store.Writer, shapes Shapes, from ShapeSize, to ShapeSize) {
+	for size := from; size <= to; size++ {
+		shapes.Iterate(size, func(s *Shape) bool {
+			if err := w.Write(s); err != nil {
+				t.Fatal(err)
+			}
+			return true
+		})
+	}
+}
+
+// reads all shapes in the file with a reader
+func readAll(t *testing.T, path string) Shapes {
+	r, err := store.NewReader(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	result := NewShapesDefaultMap()
+	for {
+		s, err := r.Next()
+		if errors.Is(err, io.EOF) {
+			return result
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		result.Add(*s)
+	}
+}
+
+func TestWriterReader(t *testing.T) {
+	for _, name := range []string{"shapes.txt", "shapes.txt.gz"} {
+		path := filepath.Join(t.TempDir(), name)
+		expected := grownShapes(5)
+
+		w, err := store.NewWriter(path, OneSided, store.Header{Key: store.METHOD_HEADER, Value: "DefaultMap"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		writeSizes(t, w, expected, 1, expected.MaxSize())
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if compressed := bytes.HasPrefix(data, []byte{0x1f, 0x8b}); compressed != store.Compressed(path) {
+			t.Fatalf("Expected %s to be compressed %v but got %v", path, store.Compressed(path), compressed)
+		}
+
+		checkShapes(t, expected, readAll(t, path))
+		shapes, err := store.ReadShapes(path, "", NewShapesDefaultMap())
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkShapes(t, expected, shapes)
+	}
+}
+
+func TestWriterAppend(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "shapes.txt")
+	expected := grownShapes(5)
+
+	w, err := store.NewWriter(path, OneSided)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeSizes(t, w, expected, 1, 3)
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// an interrupted write leaves a line without a new line behind, it is removed when shapes are added
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.WriteString("[0 0 0], [0 0"); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	w, err = store.OpenWriter(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeSizes(t, w, expected, 4, 5)
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the trailer is written again with the counts and the checksum of all shapes
+	shapes, err := store.ReadShapes(path, "", NewShapesDefaultMap())
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkShapes(t, expected, shapes)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Count(string(data), "# checksum") != 1 {
+		t.Fatalf("Expected a single checksum trailer but got %s", data)
+	}
+}
+
+func TestWriterAppendCounts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "shapes.txt")
+	store.WriteShapes(grownShapes(3), path, "")
+
+	if _, err := store.OpenWriter(path); err == nil || !strings.Contains(err.Error(), "counts in the headers") {
+		t.Fatalf("Expected an error adding shapes to a file with counts in the headers but got %v", err)
+	}
+	if _, err := store.Append(path, ""); err == nil || !strings.Contains(err.Error(), "counts in the headers") {
+		t.Fatalf("Expected an error adding shapes to a file with counts in the headers but got %v", err)
+	}
+
+	// the file is left as it was
+	if _, err := store.ReadShapes(path, "", NewShapesDefaultMap()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWriterAppendCompressed(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "shapes.txt.gz")
+	w, err := store.NewWriter(path, OneSided)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeSizes(t, w, grownShapes(3), 1, 3)
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := store.OpenWriter(path); err == nil || !strings.Contains(err.Error(), "compressed") {
+		t.Fatalf("Expected an error adding shapes to a compressed file but got %v", err)
+	}
+}
@@ -0,0 +1,278 @@
+package store
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	. "github.com/munnik/cubes/shape"
+)
+
+const TABLE_SEPARATOR = "\t"
+
+// the names of the axes used in the names of the columns with a value for every axis
+var axisNames = []string{"x", "y", "z", "w"}
+
+// Column is a column of a table of shapes, the value of the column is calculated from the shape and its properties
+type Column struct {
+	Name  string
+	Value func(s *Shape, p *Properties) float64
+}
+
+// PropertyColumns returns the columns with the geometric properties of shapes with the dimensions
+func PropertyColumns(dimensions int) []Column {
+	result := []Column{
+		{Name: "size", Value: func(s *Shape, p *Properties) float64 { return float64(p.Size) }},
+		{Name: "surface", Value: func(s *Shape, p *Properties) float64 { return float64(p.SurfaceArea) }},
+		{Name: "contacts", Value: func(s *Shape, p *Properties) float64 { return float64(p.Contacts) }},
+	}
+	for axis := 0; axis < dimensions; axis++ {
+		axis := axis
+		result = append(result, Column{Name: "box" + axisNames[axis], Value: func(s *Shape, p *Properties) float64 { return float64(p.BoundingBox[axis]) }})
+	}
+	for axis := 0; axis < dimensions; axis++ {
+		axis := axis
+		result = append(result, Column{Name: "center" + axisNames[axis], Value: func(s *Shape, p *Properties) float64 { return p.CenterOfMass[axis] }})
+	}
+	for i := 0; i < dimensions; i++ {
+		i := i
+		result = append(result, Column{Name: fmt.Sprintf("moment%d", i+1), Value: func(s *Shape, p *Properties) float64 { return p.PrincipalMoments[i] }})
+	}
+	result = append(result,
+		Column{Name: "compactness", Value: func(s *Shape, p *Properties) float64 { return p.Compactness }},
+		Column{Name: "diameter", Value: func(s *Shape, p *Properties) float64 { return float64(p.Diameter) }},
+		Column{Name: "longest", Value: func(s *Shape, p *Properties) float64 { return float64(p.LongestStraight) }},
+	)
+
+	return result
+}
+
+// TopologyColumns returns the columns with the topological invariants of shapes with the dimensions, the tunnels are
+// only included for up to 3 dimensions
+func TopologyColumns(dimensions int) []Column {
+	result := []Column{
+		{Name: "cavities", Value: func(s *Shape, p *Properties) float64 { return float64(s.Cavities()) }},
+	}
+	if dimensions <= 3 {
+		result = append(result, Column{Name: "tunnels", Value: func(s *Shape, p *Properties) float64 { return float64(s.Tunnels()) }})
+	}
+	result = append(result, Column{Name: "euler", Value: func(s *Shape, p *Properties) float64 { return float64(s.EulerCharacteristic()) }})
+
+	return result
+}
+
+// Filter keeps the rows for which the value of the column compares to Value with the operator
+type Filter struct {
+	Column   string
+	Operator string // one of <, <=, =, !=, >= and >
+	Value    float64
+}
+
+// FilterFromString parses a filter written as a column name, an operator and a number, for example cavities>0
+func FilterFromString(s string) (Filter, error) {
+	// the operators of two characters are tried first, otherwise >= would be parsed as >
+	for _, operator := range []string{"<=", ">=", "!=", "<", ">", "="} {
+		column, value, ok := strings.Cut(s, operator)
+		if !ok {
+			continue
+		}
+		v, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil {
+			return Filter{}, fmt.Errorf("filter %q should compare a column to a number: %w", s, err)
+		}
+		return Filter{Column: strings.TrimSpace(column), Operator: operator, Value: v}, nil
+	}
+
+	return Filter{}, fmt.Errorf("filter %q should contain one of the operators <, <=, =, !=, >= and >", s)
+}
+
+func (f Filter) String() string {
+	return f.Column + f.Operator + formatValue(f.Value)
+}
+
+// returns true if the value of the column passes the filter
+func (f Filter) keep(value float64) bool {
+	switch f.Operator {
+	case "<":
+		return value < f.Value
+	case "<=":
+		return value <= f.Value
+	case "=":
+		return value == f.Value
+	case "!=":
+		return value != f.Value
+	case ">=":
+		return value >= f.Value
+	case ">":
+		return value > f.Value
+	}
+
+	return false
+}
+
+// Order sorts the rows on the value of the column, the smallest value first unless Descending is true
+type Order struct {
+	Column     string
+	Descending bool
+}
+
+// OrderFromString parses an order written as a column name, prefixed with - to sort the biggest value first
+func OrderFromString(s string) Order {
+	column, descending := strings.CutPrefix(strings.TrimSpace(s), "-")
+	return Order{Column: column, Descending: descending}
+}
+
+// TableWriter writes shapes one at a time to a tab separated file, one shape per line. The first line contains the
+// names of the columns, the shape itself is written in the last column. Shapes that don't pass all filters are skipped.
+// If an order is set the rows are kept in memory and written sorted when the writer is closed.
+type TableWriter struct {
+	f       *os.File
+	w       *bufio.Writer
+	columns []Column
+	filters []Filter
+	orders  []Order
+	rows    []tableRow // the rows that are written when the writer is closed
+}
+
+type tableRow struct {
+	values []float64
+	shape  string
+}
+
+// NewTableWriter creates the file at path, the table is written to standard output if path is empty
+func NewTableWriter(path string, columns []Column) (*TableWriter, error) {
+	f := os.Stdout
+	if path != "" {
+		var err error
+		if f, err = os.Create(path); err != nil {
+			return nil, err
+		}
+	}
+
+	result := &TableWriter{f: f, w: bufio.NewWriter(f), columns: columns}
+	names := make([]string, 0, len(columns)+1)
+	for _, column := range columns {
+		names = append(names, column.Name)
+	}
+	names = append(names, "shape")
+	if _, err := fmt.Fprintln(result.w, strings.Join(names, TABLE_SEPARATOR)); err != nil {
+		result.close()
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// SetFilters sets the filters every row should pass, an error is returned if a filter uses an unknown column
+func (t *TableWriter) SetFilters(filters []Filter) error {
+	for _, filter := range filters {
+		if t.columnIndex(filter.Column) < 0 {
+			return fmt.Errorf("filter %v uses unknown column %s", filter, filter.Column)
+		}
+	}
+	t.filters = filters
+
+	return nil
+}
+
+// SetOrders sets the orders the rows are sorted on, the first order first, an error is returned if an order uses an
+// unknown column
+func (t *TableWriter) SetOrders(orders []Order) error {
+	for _, order := range orders {
+		if t.columnIndex(order.Column) < 0 {
+			return fmt.Errorf("unknown column %s to sort on", order.Column)
+		}
+	}
+	t.orders = orders
+
+	return nil
+}
+
+// returns the index of the column with the name, -1 if there is no such column
+func (t *TableWriter) columnIndex(name string) int {
+	for i, column := range t.columns {
+		if column.Name == name {
+			return i
+		}
+	}
+
+	return -1
+}
+
+func (t *TableWriter) Write(s *Shape) error {
+	properties := s.Properties()
+	row := tableRow{values: make([]float64, 0, len(t.columns)), shape: s.String()}
+	for _, column := range t.columns {
+		row.values = append(row.values, column.Value(s, properties))
+	}
+	for _, filter := range t.filters {
+		if !filter.keep(row.values[t.columnIndex(filter.Column)]) {
+			return nil
+		}
+	}
+
+	if len(t.orders) > 0 {
+		t.rows = append(t.rows, row)
+		return nil
+	}
+
+	return t.writeRow(row)
+}
+
+func (t *TableWriter) writeRow(row tableRow) error {
+	values := make([]string, 0, len(row.values)+1)
+	for _, value := range row.values {
+		values = append(values, formatValue(value))
+	}
+	values = append(values, row.shape)
+	_, err := fmt.Fprintln(t.w, strings.Join(values, TABLE_SEPARATOR))
+
+	return err
+}
+
+// Close writes the sorted rows and closes the file
+func (t *TableWriter) Close() error {
+	sort.SliceStable(t.rows, func(i, j int) bool {
+		for _, order := range t.orders {
+			index := t.columnIndex(order.Column)
+			left, right := t.rows[i].values[index], t.rows[j].values[index]
+			if left == right {
+				continue
+			}
+			return (left < right) != order.Descending
+		}
+		return false
+	})
+	for _, row := range t.rows {
+		if err := t.writeRow(row); err != nil {
+			t.close()
+			return err
+		}
+	}
+	t.rows = nil
+
+	if err := t.w.Flush(); err != nil {
+		t.close()
+		return err
+	}
+
+	return t.close()
+}
+
+// closes the file unless the table is written to standard output
+func (t *TableWriter) close() error {
+	if t.f == os.Stdout {
+		return nil
+	}
+
+	return t.f.Close()
+}
+
+// writes whole numbers without decimals and other numbers rounded to 6 decimals
+func formatValue(value float64) string {
+	return strconv.FormatFloat(math.Round(value*1e6)/1e6, 'f', -1, 64)
+}
@@ -1,46 +1,255 @@
 package store
 
 import (
-	"bufio"
 	"fmt"
-	"os"
+	"runtime/debug"
+	"sort"
+	"strconv"
+	"strings"
 
 	. "github.com/munnik/cubes/shape"
 )
 
-func WriteText(s Shapes, path string) {
-	f, err := os.Create(path)
+const (
+	HEADER_PREFIX      = "# "
+	FORMAT_HEADER      = "format"
+	VERSION_HEADER     = "version"
+	EQUIVALENCE_HEADER = "equivalence"
+	METHOD_HEADER      = "method"
+	MAX_SIZE_HEADER    = "max-size"
+	COUNT_HEADER       = "count" // the number of shapes with a size, written as size count
+	CHECKSUM_HEADER    = "checksum"
+	SHARD_HEADER       = "shard"
+	DIMENSIONS_HEADER  = "dimensions"
+	ADJACENCY_HEADER   = "adjacency"
+)
+
+// FORMAT_VERSION is the version of the text format that is written. Files without a format header are version 1, they
+// have no counts and no checksum.
+const FORMAT_VERSION = 2
+
+// the algorithm of the checksum of the lines with shapes
+const checksumAlgorithm = "crc32"
+
+// Header is a key value pair that is stored at the start of a file
+type Header struct {
+	Key   string
+	Value string
+}
+
+const (
+	TEXT_FORMAT = "text"
+	GZIP_FORMAT = "gzip"
+)
+
+func init() {
+	// text is the default format, so no extension is needed
+	RegisterFormat(Format{Name: TEXT_FORMAT, New: func() Store { return &textStore{} }})
+	RegisterFormat(Format{Name: GZIP_FORMAT, Extensions: []string{GZIP_EXTENSION}, New: func() Store { return &textStore{compressed: true} }})
+}
+
+// textStore reads text files with a Reader and writes them with a Writer, compressed files are always read but only
+// written if compressed is true
+type textStore struct {
+	compressed bool
+	r          *Reader
+	w          *Writer
+}
+
+func (t *textStore) Open(path string, complete bool) error {
+	var err error
+	t.r, err = openReader(path, complete)
+
+	return err
+}
+
+func (t *textStore) Headers() []Header {
+	if t.r == nil {
+		return nil
+	}
+
+	return t.r.Headers()
+}
+
+func (t *textStore) Read() (*Shape, error) {
+	if t.r == nil {
+		return nil, errNotOpen
+	}
+
+	return t.r.Next()
+}
+
+func (t *textStore) Create(path string, equivalence Equivalence, headers ...Header) error {
+	var err error
+	t.w, err = createWriter(path, t.compressed, equivalence, headers...)
+
+	return err
+}
+
+func (t *textStore) Append(path string) error {
+	var err error
+	t.w, err = OpenWriter(path)
+
+	return err
+}
+
+func (t *textStore) Write(s *Shape) error {
+	if t.w == nil {
+		return errNotOpen
+	}
+
+	return t.w.Write(s)
+}
+
+func (t *textStore) Flush() error {
+	if t.w == nil {
+		return errNotOpen
+	}
+
+	return t.w.Flush()
+}
+
+func (t *textStore) Close() error {
+	if t.r != nil {
+		return t.r.Close()
+	}
+	if t.w != nil {
+		return t.w.Close()
+	}
+
+	return nil
+}
+
+// returns the headers with the number of shapes of every size, the smallest size first
+func countHeaders(counts map[ShapeSize]int) []Header {
+	sizes := make([]int, 0, len(counts))
+	for size := range counts {
+		sizes = append(sizes, int(size))
+	}
+	sort.Ints(sizes)
+
+	result := make([]Header, 0, len(sizes))
+	for _, size := range sizes {
+		result = append(result, Header{Key: COUNT_HEADER, Value: fmt.Sprintf("%d %d", size, counts[ShapeSize(size)])})
+	}
+
+	return result
+}
+
+// returns the version of the module the tool is built from, (devel) if the tool isn't built from a released version
+func toolVersion() string {
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" {
+		return info.Main.Version
+	}
+
+	return "(devel)"
+}
+
+// DimensionsHeaders returns the headers that record the dimensions of the shapes in a file. Files with 3D shapes have no
+// dimensions header, like the files that were written before shapes could have other dimensions.
+func DimensionsHeaders(dimensions int) []Header {
+	if dimensions == 3 {
+		return nil
+	}
+
+	return []Header{{Key: DIMENSIONS_HEADER, Value: strconv.Itoa(dimensions)}}
+}
+
+// Dimensions returns the dimensions recorded in the headers, 3 if there is no dimensions header
+func Dimensions(headers []Header) (int, error) {
+	properties, err := readShapeProperties(headers)
 	if err != nil {
-		panic(err)
+		return 0, err
 	}
-	defer f.Close()
 
-	for size := ShapeSize(1); size <= s.MaxSize(); size++ {
-		for _, shape := range s.GetAllWithSize(size) {
-			fmt.Fprintln(f, shape)
-		}
+	return properties.dimensions, nil
+}
+
+// AdjacencyHeaders returns the headers that record the adjacency of the shapes in a file. Files with face connected
+// shapes have no adjacency header, like the files that were written before other adjacencies could be used.
+func AdjacencyHeaders(adjacency Adjacency) []Header {
+	if adjacency == Face {
+		return nil
 	}
+
+	return []Header{{Key: ADJACENCY_HEADER, Value: adjacency.String()}}
 }
 
-func ReadText(path string, shapes Shapes) (Shapes, error) {
-	f, err := os.Open(path)
+// HeaderAdjacency returns the adjacency recorded in the headers, face adjacency if there is no adjacency header
+func HeaderAdjacency(headers []Header) (Adjacency, error) {
+	properties, err := readShapeProperties(headers)
 	if err != nil {
-		return nil, err
+		return 0, err
 	}
-	defer f.Close()
 
-	scanner := bufio.NewScanner(f)
-	for scanner.Scan() {
-		shape, err := ShapeFromString(scanner.Text())
-		if err != nil {
+	return properties.adjacency, nil
+}
+
+// HeaderEquivalence returns the equivalence recorded in the headers, one-sided if there is no equivalence header like
+// the files that were written before other equivalences could be used
+func HeaderEquivalence(headers []Header) (Equivalence, error) {
+	result := OneSided
+	for _, header := range headers {
+		if header.Key == EQUIVALENCE_HEADER {
+			equivalence, err := EquivalenceFromString(header.Value)
+			if err != nil {
+				return 0, err
+			}
+			result = equivalence
+		}
+	}
+
+	return result, nil
+}
+
+// shapeProperties are the properties of the shapes in a file that are recorded in the headers but not in the lines of
+// the shapes
+type shapeProperties struct {
+	dimensions int
+	adjacency  Adjacency
+}
+
+func newShapeProperties() *shapeProperties {
+	return &shapeProperties{dimensions: 3, adjacency: Face}
+}
+
+func readShapeProperties(headers []Header) (*shapeProperties, error) {
+	result := newShapeProperties()
+	for _, header := range headers {
+		if err := result.read(header.Key + " " + header.Value); err != nil {
 			return nil, err
 		}
-		shapes.Add(*shape)
 	}
 
-	if err := scanner.Err(); err != nil {
-		panic(err)
+	return result, nil
+}
+
+// read updates the properties that are recorded by the header, other headers are ignored
+func (p *shapeProperties) read(header string) error {
+	key, value, _ := strings.Cut(header, " ")
+	switch key {
+	case DIMENSIONS_HEADER:
+		dimensions, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid dimensions header: %w", err)
+		}
+		if dimensions < 1 || dimensions > MaxDimensions {
+			return fmt.Errorf("file contains shapes with %d dimensions but at most %d dimensions are supported", dimensions, MaxDimensions)
+		}
+		p.dimensions = dimensions
+	case ADJACENCY_HEADER:
+		adjacency, err := AdjacencyFromString(value)
+		if err != nil {
+			return err
+		}
+		p.adjacency = adjacency
 	}
 
-	return shapes, nil
+	return nil
+}
+
+// set gives the shape the properties read from the headers
+func (p *shapeProperties) set(s *Shape) {
+	s.SetDimensions(p.dimensions)
+	s.SetAdjacency(p.adjacency)
 }
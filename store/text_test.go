@@ -0,0 +1,166 @@
+package store_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/munnik/cubes/shape"
+	"github.com/munnik/cubes/store"
+)
+
+// returns all one-sided shapes up to and including maxSize
+func grownShapes(maxSize ShapeSize) Shapes {
+	return NewPool(1).KeepGrowing([]*Shape{NewShape(NewShapesDefaultMap)}, maxSize)
+}
+
+// replaces the first old in the file with new
+func replaceInFile(t *testing.T, path string, old string, new string) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), old) {
+		t.Fatalf("Expected %s to contain %q", path, old)
+	}
+	if err := os.WriteFile(path, []byte(strings.Replace(string(data), old, new, 1)), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// checks that the shapes contain the same shapes as expected
+func checkShapes(t *testing.T, expected Shapes, shapes Shapes) {
+	if shapes.Len() != expected.Len() || shapes.MaxSize() != expected.MaxSize() {
+		t.Fatalf("Expected %d shapes up to size %d but got %d up to size %d", expected.Len(), expected.MaxSize(), shapes.Len(), shapes.MaxSize())
+	}
+	for score := range expected.GetAll() {
+		if shapes.Get(score) == nil {
+			t.Fatalf("Expected the shapes to contain %v", expected.Get(score))
+		}
+	}
+}
+
+func TestTextRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "shapes.txt")
+	expected := grownShapes(5)
+	store.WriteShapes(expected, path, "", store.Header{Key: store.METHOD_HEADER, Value: "DefaultMap"})
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	if lines[0] != "# format 2" {
+		t.Fatalf("Expected the file to start with the format header but got %q", lines[0])
+	}
+	if !strings.HasPrefix(lines[len(lines)-1], "# checksum crc32 ") {
+		t.Fatalf("Expected the file to end with the checksum trailer but got %q", lines[len(lines)-1])
+	}
+
+	headers, err := store.ReadHeaders(path, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	counts := 0
+	for _, header := range headers {
+		if header.Key == store.COUNT_HEADER {
+			counts++
+		}
+	}
+	if counts != 5 {
+		t.Fatalf("Expected a count header for every size but got %d", counts)
+	}
+
+	shapes, err := store.ReadShapes(path, "", NewShapesDefaultMap())
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkShapes(t, expected, shapes)
+}
+
+func TestTextChecksum(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "shapes.txt")
+	store.WriteShapes(grownShapes(4), path, "")
+
+	// a shape is changed without updating the checksum
+	replaceInFile(t, path, "[0 0 0], [0 0 1], [0 0 2]\n", "[0 0 0], [0 0 1], [0 1 1]\n")
+	if _, err := store.ReadShapes(path, "", NewShapesDefaultMap()); err == nil || !strings.Contains(err.Error(), "checksum") {
+		t.Fatalf("Expected a checksum error but got %v", err)
+	}
+}
+
+func TestTextCountMismatch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "shapes.txt")
+	store.WriteShapes(grownShapes(4), path, "")
+
+	replaceInFile(t, path, "# count 3 2\n", "# count 3 5\n")
+	if _, err := store.ReadShapes(path, "", NewShapesDefaultMap()); err == nil || !strings.Contains(err.Error(), "records 5 shapes with size 3 but contains 2") {
+		t.Fatalf("Expected a count error but got %v", err)
+	}
+}
+
+func TestTextMalformedLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "shapes.txt")
+	store.WriteShapes(grownShapes(3), path, "")
+
+	// the headers are format, version, equivalence and three counts, the second shape is on line 8
+	replaceInFile(t, path, "[0 0 0], [0 0 1]\n", "[0 0 0], [0 x 1]\n")
+	if _, err := store.ReadShapes(path, "", NewShapesDefaultMap()); err == nil || !strings.Contains(err.Error(), path+":8:") {
+		t.Fatalf("Expected an error for line 8 but got %v", err)
+	}
+}
+
+func TestTextTruncated(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "shapes.txt")
+	store.WriteShapes(grownShapes(4), path, "")
+
+	// writing the file is interrupted in the middle of the last shape
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	end := strings.LastIndex(string(data), "# checksum")
+	if err := os.WriteFile(path, data[:end-5], 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := store.ReadShapes(path, "", NewShapesDefaultMap()); err == nil || !strings.Contains(err.Error(), "interrupted") {
+		t.Fatalf("Expected an error for an interrupted file but got %v", err)
+	}
+
+	// the shapes before the interrupted line are read when a complete file isn't required
+	count := 0
+	if err := store.ScanShapes(path, "", func(s *Shape) error {
+		count++
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if count != 1+1+2+8-1 {
+		t.Fatalf("Expected all shapes but the last to be read but got %d", count)
+	}
+}
+
+func TestTextLegacy(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "shapes.txt")
+	// files without a format header have no counts and no checksum
+	if err := os.WriteFile(path, []byte("[0 0 0]\n[0 0 0], [0 0 1]\n[0 0 0], [0 0 1], [0 1 1]\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	shapes, err := store.ReadShapes(path, "", NewShapesDefaultMap())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if shapes.Len() != 3 || shapes.MaxSize() != 3 {
+		t.Fatalf("Expected 3 shapes up to size 3 but got %d up to size %d", shapes.Len(), shapes.MaxSize())
+	}
+	headers, err := store.ReadHeaders(path, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(headers) != 0 {
+		t.Fatalf("Expected no headers but got %v", headers)
+	}
+}